@@ -0,0 +1,456 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/gosom/scrapemate"
+)
+
+// Compression is the compression of an encoded entry
+type Compression byte
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZstd
+)
+
+// codecVersion is the version of the binary format written by Encode
+const codecVersion = 1
+
+// magic starts every binary entry. Older entries are gzip or plain JSON
+// so they start with 0x1f 0x8b or '{'.
+var magic = []byte{'S', 'M', 'C'}
+
+var (
+	// ErrUnsupportedVersion returned when decoding an entry written by a
+	// newer version of the codec
+	ErrUnsupportedVersion = errors.New("unsupported cache entry version")
+	// ErrCorruptEntry returned when an entry cannot be decoded
+	ErrCorruptEntry = errors.New("corrupt cache entry")
+	// ErrUndecodableEntries returned by the Iterate of the caches when they
+	// skipped entries that could not be decoded
+	ErrUndecodableEntries = errors.New("undecodable cache entries")
+)
+
+// Codec encodes responses in a compact, versioned binary format:
+//
+//	"SMC" | version (1 byte) | compression (1 byte) | payload
+//
+// The payload holds the response fields as length prefixed values.
+// Unlike JSON it keeps the error message of the response and stores
+// Body and Screenshot without base64.
+type Codec struct {
+	Compression Compression
+}
+
+// Encode encodes value
+func (c Codec) Encode(value *scrapemate.Response) ([]byte, error) {
+	var w writer
+
+	w.string(value.URL)
+	w.varint(int64(value.StatusCode))
+	w.headers(value.Headers)
+	w.varint(int64(value.Duration))
+	w.bytes(value.Body)
+
+	if value.Error != nil {
+		w.string(value.Error.Error())
+	} else {
+		w.string("")
+	}
+
+	if err := w.json(value.Meta, len(value.Meta) > 0); err != nil {
+		return nil, fmt.Errorf("cannot encode meta: %w", err)
+	}
+
+	w.bytes(value.Screenshot)
+	w.bool(value.Truncated)
+	w.time(value.CachedAt)
+
+	if err := w.json(value.Job, value.Job != nil); err != nil {
+		return nil, fmt.Errorf("cannot encode job: %w", err)
+	}
+
+	payload, err := compress(c.Compression, w.buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]byte, 0, len(magic)+2+len(payload))
+	ans = append(ans, magic...)
+	ans = append(ans, codecVersion, byte(c.Compression))
+	ans = append(ans, payload...)
+
+	return ans, nil
+}
+
+// Decode decodes an entry written by Encode. Entries written by older
+// versions of the caches, gzip compressed or plain JSON, are decoded too.
+func Decode(data []byte) (scrapemate.Response, error) {
+	if !bytes.HasPrefix(data, magic) {
+		return decodeJSON(data)
+	}
+
+	data = data[len(magic):]
+
+	const headerLen = 2
+	if len(data) < headerLen {
+		return scrapemate.Response{}, ErrCorruptEntry
+	}
+
+	if data[0] != codecVersion {
+		return scrapemate.Response{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, data[0])
+	}
+
+	payload, err := decompress(Compression(data[1]), data[headerLen:])
+	if err != nil {
+		return scrapemate.Response{}, err
+	}
+
+	r := reader{data: payload}
+
+	var ans scrapemate.Response
+
+	ans.URL = r.string()
+	ans.StatusCode = int(r.varint())
+	ans.Headers = r.headers()
+	ans.Duration = time.Duration(r.varint())
+	ans.Body = r.bytes()
+
+	if msg := r.string(); msg != "" {
+		ans.Error = errors.New(msg)
+	}
+
+	r.json(&ans.Meta)
+	ans.Screenshot = r.bytes()
+	ans.Truncated = r.bool()
+	ans.CachedAt = r.time()
+	r.json(&ans.Job)
+
+	if r.err != nil {
+		return scrapemate.Response{}, fmt.Errorf("%w: %w", ErrCorruptEntry, r.err)
+	}
+
+	return ans, nil
+}
+
+func decodeJSON(data []byte) (scrapemate.Response, error) {
+	const gzipID1, gzipID2 = 0x1f, 0x8b
+
+	if len(data) > 1 && data[0] == gzipID1 && data[1] == gzipID2 {
+		var err error
+
+		data, err = Decompress(data)
+		if err != nil {
+			return scrapemate.Response{}, err
+		}
+	}
+
+	var ans scrapemate.Response
+
+	if err := json.Unmarshal(data, &ans); err != nil {
+		return scrapemate.Response{}, fmt.Errorf("%w: %w", ErrCorruptEntry, err)
+	}
+
+	return ans, nil
+}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	errZstd     error
+)
+
+func zstdCoders() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		zstdEncoder, errZstd = zstd.NewWriter(nil)
+		if errZstd != nil {
+			return
+		}
+
+		zstdDecoder, errZstd = zstd.NewReader(nil)
+	})
+
+	return zstdEncoder, zstdDecoder, errZstd
+}
+
+func compress(c Compression, data []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		return Compress(data)
+	case CompressionZstd:
+		enc, _, err := zstdCoders()
+		if err != nil {
+			return nil, err
+		}
+
+		return enc.EncodeAll(data, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %d", c)
+	}
+}
+
+// decompress always returns a new slice so decoded responses never
+// share memory with the buffers of the caches
+func decompress(c Compression, data []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return bytes.Clone(data), nil
+	case CompressionGzip:
+		return Decompress(data)
+	case CompressionZstd:
+		_, dec, err := zstdCoders()
+		if err != nil {
+			return nil, err
+		}
+
+		return dec.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("%w: unknown compression %d", ErrCorruptEntry, c)
+	}
+}
+
+type writer struct {
+	buf bytes.Buffer
+	tmp [binary.MaxVarintLen64]byte
+}
+
+func (w *writer) varint(v int64) {
+	n := binary.PutVarint(w.tmp[:], v)
+	w.buf.Write(w.tmp[:n])
+}
+
+func (w *writer) uvarint(v uint64) {
+	n := binary.PutUvarint(w.tmp[:], v)
+	w.buf.Write(w.tmp[:n])
+}
+
+func (w *writer) bytes(b []byte) {
+	w.uvarint(uint64(len(b)))
+	w.buf.Write(b)
+}
+
+func (w *writer) string(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *writer) bool(b bool) {
+	if b {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+// time writes t as unix nanoseconds, 0 for the zero time
+func (w *writer) time(t time.Time) {
+	if t.IsZero() {
+		w.varint(0)
+
+		return
+	}
+
+	w.varint(t.UnixNano())
+}
+
+// headers writes the headers sorted by key so encoding is deterministic
+func (w *writer) headers(h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	w.uvarint(uint64(len(keys)))
+
+	for _, k := range keys {
+		w.string(k)
+		w.uvarint(uint64(len(h[k])))
+
+		for _, v := range h[k] {
+			w.string(v)
+		}
+	}
+}
+
+// json writes v as JSON, or nothing when present is false
+func (w *writer) json(v any, present bool) error {
+	if !present {
+		w.bytes(nil)
+
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	w.bytes(data)
+
+	return nil
+}
+
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *reader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail(errors.New("invalid varint"))
+
+		return 0
+	}
+
+	r.data = r.data[n:]
+
+	return v
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail(errors.New("invalid uvarint"))
+
+		return 0
+	}
+
+	r.data = r.data[n:]
+
+	return v
+}
+
+func (r *reader) bytes() []byte {
+	n := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+
+	if n > uint64(len(r.data)) {
+		r.fail(errors.New("length out of range"))
+
+		return nil
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	ans := r.data[:n:n]
+	r.data = r.data[n:]
+
+	return ans
+}
+
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+func (r *reader) bool() bool {
+	if r.err != nil {
+		return false
+	}
+
+	if len(r.data) == 0 {
+		r.fail(errors.New("unexpected end of entry"))
+
+		return false
+	}
+
+	b := r.data[0]
+	r.data = r.data[1:]
+
+	return b == 1
+}
+
+func (r *reader) time() time.Time {
+	v := r.varint()
+	if v == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, v).UTC()
+}
+
+func (r *reader) headers() http.Header {
+	n := r.uvarint()
+	if r.err != nil || n == 0 {
+		return nil
+	}
+
+	if n > uint64(len(r.data)) {
+		r.fail(errors.New("header count out of range"))
+
+		return nil
+	}
+
+	h := make(http.Header, n)
+
+	for range n {
+		k := r.string()
+
+		count := r.uvarint()
+		if count > uint64(len(r.data)) {
+			r.fail(errors.New("header value count out of range"))
+
+			return nil
+		}
+
+		values := make([]string, 0, count)
+		for range count {
+			values = append(values, r.string())
+		}
+
+		if r.err != nil {
+			return nil
+		}
+
+		h[k] = values
+	}
+
+	return h
+}
+
+// json decodes the next JSON value into v if present
+func (r *reader) json(v any) {
+	data := r.bytes()
+	if r.err != nil || len(data) == 0 {
+		return
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		r.fail(err)
+	}
+}
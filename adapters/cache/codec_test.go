@@ -0,0 +1,85 @@
+package cache_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/cache"
+)
+
+func testResponse() scrapemate.Response {
+	return scrapemate.Response{
+		URL:        "http://example.com",
+		StatusCode: 200,
+		Headers:    http.Header{"Content-Type": []string{"text/html"}, "Set-Cookie": []string{"a=1", "b=2"}},
+		Duration:   time.Second,
+		Body:       []byte("<html>hello</html>"),
+		Error:      errors.New("status code 500"),
+		Meta:       map[string]any{"charset": "utf-8"},
+		Screenshot: []byte{0x89, 'P', 'N', 'G'},
+		Truncated:  true,
+		CachedAt:   time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		Job:        &scrapemate.JobDescriptor{Type: "main.Job", Method: "GET", URL: "http://example.com"},
+	}
+}
+
+func TestCodec(t *testing.T) {
+	want := testResponse()
+
+	for _, compression := range []cache.Compression{cache.CompressionNone, cache.CompressionGzip, cache.CompressionZstd} {
+		data, err := cache.Codec{Compression: compression}.Encode(&want)
+		require.NoError(t, err)
+
+		got, err := cache.Decode(data)
+		require.NoError(t, err)
+		require.EqualError(t, got.Error, want.Error.Error())
+
+		got.Error = want.Error
+		require.Equal(t, want, got)
+	}
+
+	t.Run("empty response", func(t *testing.T) {
+		data, err := cache.Codec{}.Encode(&scrapemate.Response{})
+		require.NoError(t, err)
+
+		got, err := cache.Decode(data)
+		require.NoError(t, err)
+		require.Equal(t, scrapemate.Response{}, got)
+	})
+	t.Run("legacy json", func(t *testing.T) {
+		legacy := testResponse()
+		legacy.Error = nil
+
+		data, err := json.Marshal(&legacy)
+		require.NoError(t, err)
+
+		got, err := cache.Decode(data)
+		require.NoError(t, err)
+		require.Equal(t, legacy.Body, got.Body)
+		require.Equal(t, legacy.Headers, got.Headers)
+
+		compressed, err := cache.Compress(data)
+		require.NoError(t, err)
+
+		got, err = cache.Decode(compressed)
+		require.NoError(t, err)
+		require.Equal(t, legacy.Body, got.Body)
+	})
+	t.Run("corrupt", func(t *testing.T) {
+		data, err := cache.Codec{}.Encode(&want)
+		require.NoError(t, err)
+
+		_, err = cache.Decode(data[:len(data)/2])
+		require.ErrorIs(t, err, cache.ErrCorruptEntry)
+
+		data[3] = 99
+		_, err = cache.Decode(data)
+		require.ErrorIs(t, err, cache.ErrUnsupportedVersion)
+	})
+}
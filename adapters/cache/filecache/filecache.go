@@ -2,90 +2,447 @@ package filecache
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/md5" //nolint:gosec // used to spread the keys, not for security
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gosom/scrapemate"
 	"github.com/gosom/scrapemate/adapters/cache"
 )
 
-var _ scrapemate.Cacher = (*FileCache)(nil)
+var (
+	_ scrapemate.Cacher     = (*FileCache)(nil)
+	_ scrapemate.CacheAdmin = (*FileCache)(nil)
+)
+
+// ErrReadOnly returned when writing to a cache opened with WithReadOnly
+var ErrReadOnly = errors.New("cache is read only")
+
+// errDecode wraps the errors of entries that cannot be decoded
+var errDecode = errors.New("cannot decode file")
+
+const (
+	dirPermissions = 0o777
+	// tempPrefix starts the names of entries being written
+	tempPrefix = ".tmp-"
+	// shardLen is the number of hex characters of the shard directories
+	shardLen = 2
+)
 
-// FileCache is a file cache
+// FileCache is a file cache.
+// Entries are stored in subdirectories named after the first two hex
+// characters of the MD5 of their key and are written atomically.
+// With WithMaxSize the least recently used entries are evicted when the
+// cache grows over the limit. A cache directory must be used by a single
+// FileCache at a time.
 type FileCache struct {
-	folder string
+	folder   string
+	codec    cache.Codec
+	maxSize  int64
+	lru      *lru
+	readOnly bool
+	// mu serializes writes, deletions and evictions when the size is
+	// bounded so the lru matches the files on disk
+	mu sync.Mutex
+}
+
+// Option configures a FileCache
+type Option func(*FileCache)
+
+// WithCompression sets the compression of new entries (default zstd)
+func WithCompression(compression cache.Compression) Option {
+	return func(c *FileCache) {
+		c.codec.Compression = compression
+	}
 }
 
-// NewFileCache creates a new file cache
-func NewFileCache(folder string) (*FileCache, error) {
-	const permissions = 0o777
-	if err := os.MkdirAll(folder, permissions); err != nil {
+// WithMaxSize bounds the size of the cache on disk evicting the least
+// recently used entries. The entry just written is never evicted.
+// Zero or negative means unbounded, the default.
+func WithMaxSize(maxBytes int64) Option {
+	return func(c *FileCache) {
+		c.maxSize = maxBytes
+	}
+}
+
+// WithReadOnly opens an existing cache without modifying it: the folder is
+// neither created nor migrated and writes fail with ErrReadOnly. Entries
+// of caches written by older versions are read in place.
+func WithReadOnly() Option {
+	return func(c *FileCache) {
+		c.readOnly = true
+	}
+}
+
+// NewFileCache creates a new file cache.
+// Entries of caches written by older versions, stored directly in folder,
+// are moved to their subdirectories.
+func NewFileCache(folder string, options ...Option) (*FileCache, error) {
+	c := FileCache{
+		folder: folder,
+		codec:  cache.Codec{Compression: cache.CompressionZstd},
+	}
+
+	for _, opt := range options {
+		opt(&c)
+	}
+
+	if c.readOnly {
+		info, err := os.Stat(folder)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open cache dir %w", err)
+		}
+
+		if !info.IsDir() {
+			return nil, fmt.Errorf("cannot open cache dir: %s is not a directory", folder)
+		}
+
+		return &c, nil
+	}
+
+	if err := os.MkdirAll(folder, dirPermissions); err != nil {
 		return nil, fmt.Errorf("cannot create cache dir %w", err)
 	}
 
-	return &FileCache{folder: folder}, nil
+	if err := c.migrate(); err != nil {
+		return nil, err
+	}
+
+	if c.maxSize > 0 {
+		var err error
+
+		if c.lru, err = c.loadLRU(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &c, nil
 }
 
 // Get gets a value from the cache
-func (c *FileCache) Get(_ context.Context, key string) (scrapemate.Response, error) {
-	file := filepath.Join(c.folder, key)
+func (c *FileCache) Get(ctx context.Context, key string) (scrapemate.Response, error) {
+	response, legacy, err := c.read(c.path(key))
+	if err != nil && c.readOnly && errors.Is(err, fs.ErrNotExist) {
+		// the cache may not be migrated
+		if flat := filepath.Join(c.folder, key); isLegacyEntry(flat, key) {
+			response, _, err = c.read(flat)
+		}
+	}
+
+	if err != nil {
+		return scrapemate.Response{}, err
+	}
+
+	if c.lru != nil {
+		if legacy {
+			// rewrite it so the store time survives touching the file
+			_ = c.Set(ctx, key, &response)
+		} else {
+			c.touch(key)
+		}
+	}
+
+	return response, nil
+}
+
+// Set sets a value to the cache.
+// The entry is stamped with the current time unless value.CachedAt is set.
+func (c *FileCache) Set(_ context.Context, key string, value *scrapemate.Response) error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+
+	data, err := c.codec.Encode(cache.Stamp(value))
+	if err != nil {
+		return fmt.Errorf("cannot encode response %w", err)
+	}
+
+	file := c.path(key)
+
+	if c.lru == nil {
+		return writeFile(file, data)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := writeFile(file, data); err != nil {
+		return err
+	}
+
+	for _, evicted := range c.lru.add(key, int64(len(data)), c.maxSize) {
+		_ = c.deleteFile(evicted)
+	}
+
+	return nil
+}
+
+// Close closes the file cache
+func (c *FileCache) Close() error {
+	return nil
+}
+
+// Delete removes the entry with key from the cache
+func (c *FileCache) Delete(_ context.Context, key string) error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+
+	if c.lru != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.lru.remove(key)
+	}
+
+	return c.deleteFile(key)
+}
+
+func (c *FileCache) deleteFile(key string) error {
+	err := os.Remove(c.path(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("cannot delete %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// Purge removes the entries whose key starts with prefix
+func (c *FileCache) Purge(ctx context.Context, prefix string) (int, error) {
+	if c.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	var removed int
+
+	err := c.walk(ctx, func(key, _ string, _ fs.DirEntry) error {
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		if err := c.Delete(ctx, key); err != nil {
+			return err
+		}
+
+		removed++
+
+		return nil
+	})
+
+	return removed, err
+}
+
+// Iterate calls fn for every cached entry. Entries that cannot be decoded
+// are skipped and reported with a cache.ErrUndecodableEntries error once
+// the other entries have been iterated.
+func (c *FileCache) Iterate(ctx context.Context, fn func(key string, value *scrapemate.Response) error) error {
+	var (
+		skipped  int
+		firstKey string
+		firstErr error
+	)
+
+	err := c.walk(ctx, func(key, file string, _ fs.DirEntry) error {
+		response, _, err := c.read(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			// deleted while iterating
+			return nil
+		}
+
+		if errors.Is(err, errDecode) {
+			if skipped == 0 {
+				firstKey, firstErr = key, err
+			}
+
+			skipped++
+
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return fn(key, &response)
+	})
+	if err != nil {
+		return err
+	}
+
+	if skipped > 0 {
+		return fmt.Errorf("%w: skipped %d, first %q: %v", cache.ErrUndecodableEntries, skipped, firstKey, firstErr)
+	}
+
+	return nil
+}
+
+// Stats returns the number of entries and their size on disk
+func (c *FileCache) Stats(ctx context.Context) (scrapemate.CacheStats, error) {
+	var stats scrapemate.CacheStats
+
+	err := c.walk(ctx, func(_, _ string, entry fs.DirEntry) error {
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+
+		stats.Entries++
+		stats.Bytes += info.Size()
+
+		return nil
+	})
+
+	return stats, err
+}
+
+// path returns the file of the entry with key
+func (c *FileCache) path(key string) string {
+	return filepath.Join(c.folder, shard(key), key)
+}
+
+func shard(key string) string {
+	sum := md5.Sum([]byte(key)) //nolint:gosec // used to spread the keys, not for security
+
+	return hex.EncodeToString(sum[:])[:shardLen]
+}
 
+// walk calls fn for every entry in the shard directories, and for the
+// entries of older versions in a read only cache
+func (c *FileCache) walk(ctx context.Context, fn func(key, file string, entry fs.DirEntry) error) error {
+	shards, err := os.ReadDir(c.folder)
+	if err != nil {
+		return fmt.Errorf("cannot read cache dir %w", err)
+	}
+
+	for _, s := range shards {
+		if c.readOnly && s.Type().IsRegular() {
+			file := filepath.Join(c.folder, s.Name())
+
+			if isLegacyEntry(file, s.Name()) {
+				if err := fn(s.Name(), file, s); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		if !s.IsDir() || len(s.Name()) != shardLen {
+			continue
+		}
+
+		dir := filepath.Join(c.folder, s.Name())
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("cannot read cache dir %w", err)
+		}
+
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), tempPrefix) {
+				continue
+			}
+
+			if err := fn(entry.Name(), filepath.Join(dir, entry.Name()), entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// touch marks the entry as recently used, in memory and on disk so the
+// order survives restarts
+func (c *FileCache) touch(key string) {
+	c.lru.touch(key)
+
+	now := time.Now()
+	_ = os.Chtimes(c.path(key), now, now)
+}
+
+// read decodes the entry in file. legacy is true for entries that were
+// written without a store time.
+func (c *FileCache) read(file string) (response scrapemate.Response, legacy bool, err error) {
 	f, err := os.Open(file)
 	if err != nil {
-		return scrapemate.Response{}, fmt.Errorf("cannot open file %s: %w", file, err)
+		return scrapemate.Response{}, false, fmt.Errorf("cannot open file %s: %w", file, err)
 	}
 
 	defer f.Close()
 
 	data, err := io.ReadAll(f)
 	if err != nil {
-		return scrapemate.Response{}, fmt.Errorf("cannot read file %s: %w", file, err)
+		return scrapemate.Response{}, false, fmt.Errorf("cannot read file %s: %w", file, err)
 	}
 
-	decompressed, err := cache.Decompress(data)
+	response, err = cache.Decode(data)
 	if err != nil {
-		return scrapemate.Response{}, fmt.Errorf("cannot decompress file %s: %w", file, err)
+		return scrapemate.Response{}, false, fmt.Errorf("%w %s: %w", errDecode, file, err)
 	}
 
-	var response scrapemate.Response
+	// entries written before store times were recorded use the file's mtime
+	if response.CachedAt.IsZero() {
+		legacy = true
 
-	if err := json.Unmarshal(decompressed, &response); err != nil {
-		return scrapemate.Response{}, fmt.Errorf("cannot unmarshal file %s: %w", file, err)
+		if info, err := f.Stat(); err == nil {
+			response.CachedAt = info.ModTime().UTC()
+		}
 	}
 
-	return response, nil
+	return response, legacy, nil
 }
 
-// Set sets a value to the cache
-func (c *FileCache) Set(_ context.Context, key string, value *scrapemate.Response) error {
-	f, err := os.Create(filepath.Join(c.folder, key))
+// writeFile writes data to a temporary file and renames it to file so
+// readers never see partially written entries
+func writeFile(file string, data []byte) (err error) {
+	dir := filepath.Dir(file)
+
+	if err := os.MkdirAll(dir, dirPermissions); err != nil {
+		return fmt.Errorf("cannot create cache dir %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, tempPrefix+"*")
 	if err != nil {
 		return fmt.Errorf("cannot create file %w", err)
 	}
 
-	defer f.Close()
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
 
-	data, err := json.Marshal(value)
-	if err != nil {
-		return fmt.Errorf("cannot marshal response %w", err)
+	const filePermissions = 0o644
+
+	if err := f.Chmod(filePermissions); err != nil {
+		return fmt.Errorf("cannot create file %w", err)
 	}
 
-	compressed, err := cache.Compress(data)
-	if err != nil {
-		return fmt.Errorf("cannot compress data %w", err)
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("cannot write to file %w", err)
 	}
 
-	if _, err := f.Write(compressed); err != nil {
+	if err := f.Close(); err != nil {
 		return fmt.Errorf("cannot write to file %w", err)
 	}
 
-	return nil
-}
+	if err := os.Rename(f.Name(), file); err != nil {
+		return fmt.Errorf("cannot rename file %w", err)
+	}
 
-// Close closes the file cache
-func (c *FileCache) Close() error {
 	return nil
 }
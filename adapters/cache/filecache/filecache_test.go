@@ -0,0 +1,212 @@
+package filecache_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/cache"
+	"github.com/gosom/scrapemate/adapters/cache/filecache"
+)
+
+func TestFileCache_Admin(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := filecache.NewFileCache(t.TempDir())
+	require.NoError(t, err)
+
+	for _, key := range []string{"aa1", "aa2", "bb1"} {
+		require.NoError(t, c.Set(ctx, key, &scrapemate.Response{URL: key, StatusCode: 200}))
+	}
+
+	stats, err := c.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, stats.Entries)
+	require.Positive(t, stats.Bytes)
+
+	seen := map[string]string{}
+	err = c.Iterate(ctx, func(key string, value *scrapemate.Response) error {
+		seen[key] = value.URL
+		require.False(t, value.CachedAt.IsZero())
+
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"aa1": "aa1", "aa2": "aa2", "bb1": "bb1"}, seen)
+
+	require.NoError(t, c.Delete(ctx, "bb1"))
+	require.NoError(t, c.Delete(ctx, "bb1"))
+
+	_, err = c.Get(ctx, "bb1")
+	require.Error(t, err)
+
+	removed, err := c.Purge(ctx, "aa")
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+
+	stats, err = c.Stats(ctx)
+	require.NoError(t, err)
+	require.Zero(t, stats.Entries)
+}
+
+func TestFileCache_IterateSkipsUndecodable(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	c, err := filecache.NewFileCache(dir)
+	require.NoError(t, err)
+
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, c.Set(ctx, key, &scrapemate.Response{URL: key, StatusCode: 200}))
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*", "b"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.NoError(t, os.WriteFile(files[0], []byte("garbage"), 0o600))
+
+	var seen []string
+
+	err = c.Iterate(ctx, func(key string, _ *scrapemate.Response) error {
+		seen = append(seen, key)
+
+		return nil
+	})
+	require.ErrorIs(t, err, cache.ErrUndecodableEntries)
+	require.ErrorContains(t, err, `skipped 1, first "b"`)
+	require.ElementsMatch(t, []string{"a", "c"}, seen)
+}
+
+func TestFileCache_Migration(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// a flat entry written by an older version: gzip compressed JSON
+	data, err := json.Marshal(&scrapemate.Response{URL: "http://example.com", StatusCode: 200})
+	require.NoError(t, err)
+
+	compressed, err := cache.Compress(data)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "oldkey"), compressed, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".tmp-123"), []byte("partial"), 0o600))
+	// files that are not cache entries are left alone
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("notes"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".hidden"), compressed, 0o600))
+
+	c, err := filecache.NewFileCache(dir)
+	require.NoError(t, err)
+
+	resp, err := c.Get(ctx, "oldkey")
+	require.NoError(t, err)
+	require.Equal(t, "http://example.com", resp.URL)
+	require.False(t, resp.CachedAt.IsZero())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	require.Len(t, entries, 3)
+	require.Equal(t, []string{".hidden", "README"}, names)
+}
+
+func TestFileCache_MaxSize(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	c, err := filecache.NewFileCache(dir, filecache.WithMaxSize(2500), filecache.WithCompression(cache.CompressionNone))
+	require.NoError(t, err)
+
+	body := make([]byte, 1000)
+
+	require.NoError(t, c.Set(ctx, "a", &scrapemate.Response{Body: body}))
+	require.NoError(t, c.Set(ctx, "b", &scrapemate.Response{Body: body}))
+
+	// a is now the most recently used
+	_, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+
+	require.NoError(t, c.Set(ctx, "c", &scrapemate.Response{Body: body}))
+
+	_, err = c.Get(ctx, "b")
+	require.Error(t, err)
+
+	for _, key := range []string{"a", "c"} {
+		_, err = c.Get(ctx, key)
+		require.NoError(t, err)
+	}
+
+	stats, err := c.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.Entries)
+	require.LessOrEqual(t, stats.Bytes, int64(2500))
+
+	// the limit is enforced when reopening with a smaller one
+	c, err = filecache.NewFileCache(dir, filecache.WithMaxSize(1500))
+	require.NoError(t, err)
+
+	stats, err = c.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.Entries)
+}
+
+func TestFileCache_ConcurrentMaxSize(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	const maxSize = 5000
+
+	c, err := filecache.NewFileCache(dir, filecache.WithMaxSize(maxSize), filecache.WithCompression(cache.CompressionNone))
+	require.NoError(t, err)
+
+	body := make([]byte, 1000)
+
+	var wg sync.WaitGroup
+
+	for i := range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := range 100 {
+				key := strconv.Itoa((i + j) % 10)
+
+				if j%7 == 0 {
+					require.NoError(t, c.Delete(ctx, key))
+				} else {
+					require.NoError(t, c.Set(ctx, key, &scrapemate.Response{Body: body}))
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	stats, err := c.Stats(ctx)
+	require.NoError(t, err)
+	require.LessOrEqual(t, stats.Bytes, int64(maxSize))
+
+	// the lru and the disk agree: filling the cache keeps it full
+	for i := range 10 {
+		require.NoError(t, c.Set(ctx, "fill"+strconv.Itoa(i), &scrapemate.Response{Body: body}))
+	}
+
+	stats, err = c.Stats(ctx)
+	require.NoError(t, err)
+	require.Greater(t, stats.Bytes, int64(maxSize-1100))
+	require.LessOrEqual(t, stats.Bytes, int64(maxSize))
+}
@@ -0,0 +1,125 @@
+package filecache
+
+import (
+	"container/list"
+	"context"
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+)
+
+// lru tracks the size and the order of use of the entries
+type lru struct {
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	size  int64
+}
+
+type lruEntry struct {
+	key  string
+	size int64
+}
+
+func newLRU() *lru {
+	return &lru{
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// add records the entry as the most recently used one and returns the
+// keys that must be evicted to stay under maxSize
+func (l *lru) add(key string, size, maxSize int64) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		//nolint:errcheck // only *lruEntry values are stored
+		e := el.Value.(*lruEntry)
+
+		l.size += size - e.size
+		e.size = size
+		l.ll.MoveToFront(el)
+	} else {
+		l.items[key] = l.ll.PushFront(&lruEntry{key: key, size: size})
+		l.size += size
+	}
+
+	var evicted []string
+
+	for l.size > maxSize && l.ll.Len() > 1 {
+		//nolint:errcheck // only *lruEntry values are stored
+		e := l.ll.Remove(l.ll.Back()).(*lruEntry)
+
+		delete(l.items, e.key)
+		l.size -= e.size
+
+		evicted = append(evicted, e.key)
+	}
+
+	return evicted
+}
+
+func (l *lru) touch(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		l.ll.MoveToFront(el)
+	}
+}
+
+func (l *lru) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		//nolint:errcheck // only *lruEntry values are stored
+		e := l.ll.Remove(el).(*lruEntry)
+
+		delete(l.items, key)
+		l.size -= e.size
+	}
+}
+
+// loadLRU builds the lru from the entries on disk ordered by modification
+// time, which Get updates, and evicts entries over the max size
+func (c *FileCache) loadLRU() (*lru, error) {
+	type fileInfo struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []fileInfo
+
+	err := c.walk(context.Background(), func(key, _ string, entry fs.DirEntry) error {
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+
+		files = append(files, fileInfo{key: key, size: info.Size(), modTime: info.ModTime()})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	l := newLRU()
+
+	for _, f := range files {
+		for _, evicted := range l.add(f.key, f.size, c.maxSize) {
+			_ = c.deleteFile(evicted)
+		}
+	}
+
+	return l, nil
+}
@@ -0,0 +1,82 @@
+package filecache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// legacyMagic starts the entries written by older versions: gzip
+// compressed JSON
+var legacyMagic = []byte{0x1f, 0x8b}
+
+// migrate moves the entries of flat caches, written directly in the cache
+// folder by older versions, to their shard directories and removes
+// temporary files left by interrupted writes. Other files are left alone.
+func (c *FileCache) migrate() error {
+	entries, err := os.ReadDir(c.folder)
+	if err != nil {
+		return fmt.Errorf("cannot read cache dir %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		file := filepath.Join(c.folder, name)
+
+		switch {
+		case entry.IsDir():
+			if len(name) == shardLen {
+				c.removeTempFiles(file)
+			}
+		case !entry.Type().IsRegular():
+		case strings.HasPrefix(name, tempPrefix):
+			_ = os.Remove(file)
+		case !isLegacyEntry(file, name):
+		default:
+			dst := c.path(name)
+
+			if err := os.MkdirAll(filepath.Dir(dst), dirPermissions); err != nil {
+				return fmt.Errorf("cannot create cache dir %w", err)
+			}
+
+			if err := os.Rename(file, dst); err != nil {
+				return fmt.Errorf("cannot migrate %s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isLegacyEntry tells whether the file name in the cache folder is an
+// entry written by an older version
+func isLegacyEntry(file, name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+
+	defer f.Close()
+
+	header := make([]byte, len(legacyMagic))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+
+	return bytes.Equal(header, legacyMagic)
+}
+
+func (c *FileCache) removeTempFiles(dir string) {
+	matches, _ := filepath.Glob(filepath.Join(dir, tempPrefix+"*"))
+
+	for _, m := range matches {
+		_ = os.Remove(m)
+	}
+}
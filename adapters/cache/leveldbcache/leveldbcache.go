@@ -2,27 +2,66 @@ package leveldbcache
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 
 	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/cache"
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
-var _ scrapemate.Cacher = (*LevelDBCache)(nil)
+var (
+	_ scrapemate.Cacher     = (*LevelDBCache)(nil)
+	_ scrapemate.CacheAdmin = (*LevelDBCache)(nil)
+)
+
+// purgeBatchSize is the number of deletions Purge writes at once
+const purgeBatchSize = 1000
 
 // LevelDBCache is a cache that uses LevelDB as a backend.
 type LevelDBCache struct {
-	db *leveldb.DB
+	db       *leveldb.DB
+	codec    cache.Codec
+	readOnly bool
+}
+
+// Option configures a LevelDBCache.
+type Option func(*LevelDBCache)
+
+// WithCompression sets the compression of new entries (default zstd).
+func WithCompression(compression cache.Compression) Option {
+	return func(c *LevelDBCache) {
+		c.codec.Compression = compression
+	}
+}
+
+// WithReadOnly opens an existing database without modifying it.
+// Opening fails when there is no database at path and writes fail.
+func WithReadOnly() Option {
+	return func(c *LevelDBCache) {
+		c.readOnly = true
+	}
 }
 
 // NewLevelDBCache creates a new LevelDBCache.
-func NewLevelDBCache(path string) (*LevelDBCache, error) {
-	db, err := leveldb.OpenFile(path, nil)
+func NewLevelDBCache(path string, options ...Option) (*LevelDBCache, error) {
+	c := LevelDBCache{
+		codec: cache.Codec{Compression: cache.CompressionZstd},
+	}
+
+	for _, o := range options {
+		o(&c)
+	}
+
+	var err error
+
+	c.db, err = leveldb.OpenFile(path, &opt.Options{ReadOnly: c.readOnly, ErrorIfMissing: c.readOnly})
 	if err != nil {
 		return nil, err
 	}
 
-	return &LevelDBCache{db: db}, nil
+	return &c, nil
 }
 
 // Get gets a value from the cache.
@@ -32,17 +71,13 @@ func (c *LevelDBCache) Get(_ context.Context, key string) (scrapemate.Response,
 		return scrapemate.Response{}, err
 	}
 
-	var response scrapemate.Response
-	if err := json.Unmarshal(data, &response); err != nil {
-		return scrapemate.Response{}, err
-	}
-
-	return response, nil
+	return cache.Decode(data)
 }
 
 // Set sets a value to the cache.
+// The entry is stamped with the current time unless value.CachedAt is set.
 func (c *LevelDBCache) Set(_ context.Context, key string, value *scrapemate.Response) error {
-	data, err := json.Marshal(value)
+	data, err := c.codec.Encode(cache.Stamp(value))
 	if err != nil {
 		return err
 	}
@@ -50,6 +85,117 @@ func (c *LevelDBCache) Set(_ context.Context, key string, value *scrapemate.Resp
 	return c.db.Put([]byte(key), data, nil)
 }
 
+// Delete removes the entry with key from the cache.
+func (c *LevelDBCache) Delete(_ context.Context, key string) error {
+	return c.db.Delete([]byte(key), nil)
+}
+
+// Purge removes the entries whose key starts with prefix.
+func (c *LevelDBCache) Purge(ctx context.Context, prefix string) (int, error) {
+	iter := c.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	defer iter.Release()
+
+	var (
+		batch   leveldb.Batch
+		removed int
+	)
+
+	flush := func() error {
+		if err := c.db.Write(&batch, nil); err != nil {
+			return err
+		}
+
+		removed += batch.Len()
+		batch.Reset()
+
+		return nil
+	}
+
+	for iter.Next() {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+
+		batch.Delete(iter.Key())
+
+		if batch.Len() >= purgeBatchSize {
+			if err := flush(); err != nil {
+				return removed, err
+			}
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return removed, err
+	}
+
+	if err := flush(); err != nil {
+		return removed, err
+	}
+
+	return removed, nil
+}
+
+// Iterate calls fn for every cached entry. Entries that cannot be decoded
+// are skipped and reported with a cache.ErrUndecodableEntries error once
+// the other entries have been iterated.
+func (c *LevelDBCache) Iterate(ctx context.Context, fn func(key string, value *scrapemate.Response) error) error {
+	iter := c.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	var (
+		skipped  int
+		firstKey string
+		firstErr error
+	)
+
+	for iter.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		response, err := cache.Decode(iter.Value())
+		if err != nil {
+			if skipped == 0 {
+				firstKey, firstErr = string(iter.Key()), err
+			}
+
+			skipped++
+
+			continue
+		}
+
+		if err := fn(string(iter.Key()), &response); err != nil {
+			return err
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	if skipped > 0 {
+		return fmt.Errorf("%w: skipped %d, first %q: %v", cache.ErrUndecodableEntries, skipped, firstKey, firstErr)
+	}
+
+	return nil
+}
+
+// Stats returns the number of entries and the size of their keys and values.
+func (c *LevelDBCache) Stats(_ context.Context) (scrapemate.CacheStats, error) {
+	iter := c.db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	var stats scrapemate.CacheStats
+
+	for iter.Next() {
+		stats.Entries++
+		stats.Bytes += int64(len(iter.Key()) + len(iter.Value()))
+	}
+
+	return stats, iter.Error()
+}
+
 // Close closes the LevelDBCache.
 func (c *LevelDBCache) Close() error {
 	return c.db.Close()
@@ -0,0 +1,111 @@
+package leveldbcache_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/cache"
+	"github.com/gosom/scrapemate/adapters/cache/leveldbcache"
+)
+
+func TestLevelDBCache_Admin(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := leveldbcache.NewLevelDBCache(t.TempDir())
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = c.Close() })
+
+	for _, key := range []string{"aa1", "aa2", "bb1"} {
+		require.NoError(t, c.Set(ctx, key, &scrapemate.Response{URL: key, StatusCode: 200}))
+	}
+
+	stats, err := c.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, stats.Entries)
+	require.Positive(t, stats.Bytes)
+
+	seen := map[string]string{}
+	err = c.Iterate(ctx, func(key string, value *scrapemate.Response) error {
+		seen[key] = value.URL
+		require.False(t, value.CachedAt.IsZero())
+
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"aa1": "aa1", "aa2": "aa2", "bb1": "bb1"}, seen)
+
+	require.NoError(t, c.Delete(ctx, "bb1"))
+	require.NoError(t, c.Delete(ctx, "bb1"))
+
+	_, err = c.Get(ctx, "bb1")
+	require.Error(t, err)
+
+	removed, err := c.Purge(ctx, "aa")
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+
+	stats, err = c.Stats(ctx)
+	require.NoError(t, err)
+	require.Zero(t, stats.Entries)
+}
+
+func TestLevelDBCache_PurgeInBatches(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := leveldbcache.NewLevelDBCache(t.TempDir())
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = c.Close() })
+
+	for i := range 2500 {
+		require.NoError(t, c.Set(ctx, fmt.Sprintf("aa%d", i), &scrapemate.Response{StatusCode: 200}))
+	}
+
+	require.NoError(t, c.Set(ctx, "bb", &scrapemate.Response{StatusCode: 200}))
+
+	removed, err := c.Purge(ctx, "aa")
+	require.NoError(t, err)
+	require.Equal(t, 2500, removed)
+
+	stats, err := c.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.Entries)
+}
+
+func TestLevelDBCache_IterateSkipsUndecodable(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	c, err := leveldbcache.NewLevelDBCache(dir)
+	require.NoError(t, err)
+	require.NoError(t, c.Set(ctx, "a", &scrapemate.Response{URL: "a", StatusCode: 200}))
+	require.NoError(t, c.Set(ctx, "c", &scrapemate.Response{URL: "c", StatusCode: 200}))
+	require.NoError(t, c.Close())
+
+	db, err := leveldb.OpenFile(dir, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Put([]byte("b"), []byte("garbage"), nil))
+	require.NoError(t, db.Close())
+
+	c, err = leveldbcache.NewLevelDBCache(dir)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = c.Close() })
+
+	var seen []string
+
+	err = c.Iterate(ctx, func(key string, _ *scrapemate.Response) error {
+		seen = append(seen, key)
+
+		return nil
+	})
+	require.ErrorIs(t, err, cache.ErrUndecodableEntries)
+	require.ErrorContains(t, err, `skipped 1, first "b"`)
+	require.Equal(t, []string{"a", "c"}, seen)
+}
@@ -0,0 +1,20 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/gosom/scrapemate"
+)
+
+// Stamp returns value with CachedAt set to the current time if it's zero.
+// value itself is not modified.
+func Stamp(value *scrapemate.Response) *scrapemate.Response {
+	if !value.CachedAt.IsZero() {
+		return value
+	}
+
+	stamped := *value
+	stamped.CachedAt = time.Now().UTC()
+
+	return &stamped
+}
@@ -0,0 +1,348 @@
+// Package tieredcache provides a Cacher that keeps the most recently used
+// responses in memory in front of a persistent Cacher.
+//
+//	backend, err := leveldbcache.NewLevelDBCache("cache")
+//	cache, err := tieredcache.New(backend, tieredcache.WithMaxEntries(1000))
+package tieredcache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"sync"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/cache"
+)
+
+var (
+	_ scrapemate.Cacher     = (*TieredCache)(nil)
+	_ scrapemate.CacheAdmin = (*TieredCache)(nil)
+)
+
+const (
+	defaultMaxEntries = 1024
+	defaultMaxBytes   = 256 << 20
+)
+
+// ErrInvalidLimit returned when a memory limit is not positive
+var ErrInvalidLimit = errors.New("memory limit must be positive")
+
+// Option configures a TieredCache
+type Option func(*TieredCache) error
+
+// WithMaxEntries sets how many responses are kept in memory (default 1024)
+func WithMaxEntries(n int) Option {
+	return func(c *TieredCache) error {
+		if n <= 0 {
+			return ErrInvalidLimit
+		}
+
+		c.maxEntries = n
+
+		return nil
+	}
+}
+
+// WithMaxBytes sets the approximate memory used by the responses kept in
+// memory (default 256MiB). Responses larger than it are not kept in memory.
+func WithMaxBytes(n int64) Option {
+	return func(c *TieredCache) error {
+		if n <= 0 {
+			return ErrInvalidLimit
+		}
+
+		c.maxBytes = n
+
+		return nil
+	}
+}
+
+// TieredCache is an in-memory LRU cache in front of a persistent Cacher.
+// Reads from the backend are promoted to memory and writes go to both.
+type TieredCache struct {
+	backend    scrapemate.Cacher
+	maxEntries int
+	maxBytes   int64
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	bytes int64
+	// loads tracks the keys being read from the backend
+	loads map[string]*load
+}
+
+type entry struct {
+	key   string
+	value scrapemate.Response
+	size  int64
+}
+
+// load is a read from the backend in progress. It is stale when the key
+// was written or removed meanwhile, so the value read must not be kept.
+type load struct {
+	readers int
+	stale   bool
+}
+
+// New creates a TieredCache over backend
+func New(backend scrapemate.Cacher, options ...Option) (*TieredCache, error) {
+	if backend == nil {
+		return nil, scrapemate.ErrorNoCacher
+	}
+
+	c := TieredCache{
+		backend:    backend,
+		maxEntries: defaultMaxEntries,
+		maxBytes:   defaultMaxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		loads:      make(map[string]*load),
+	}
+
+	for _, opt := range options {
+		if err := opt(&c); err != nil {
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+// Get returns the response from memory or from the backend
+func (c *TieredCache) Get(ctx context.Context, key string) (scrapemate.Response, error) {
+	c.mu.Lock()
+
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+
+		//nolint:errcheck // only *entry values are stored
+		value := clone(&el.Value.(*entry).value)
+
+		c.mu.Unlock()
+
+		return value, nil
+	}
+
+	l, ok := c.loads[key]
+	if !ok {
+		l = &load{}
+		c.loads[key] = l
+	}
+
+	l.readers++
+
+	c.mu.Unlock()
+
+	value, err := c.backend.Get(ctx, key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l.readers--
+	if l.readers == 0 {
+		delete(c.loads, key)
+	}
+
+	if err != nil {
+		return scrapemate.Response{}, err
+	}
+
+	// a concurrent Set already kept a newer response
+	if !l.stale {
+		c.addLocked(key, &value)
+	}
+
+	return value, nil
+}
+
+// Set writes the response to the backend and keeps it in memory. It's
+// stamped with its store time once so both tiers hold the same CachedAt.
+func (c *TieredCache) Set(ctx context.Context, key string, value *scrapemate.Response) error {
+	value = cache.Stamp(value)
+
+	if err := c.backend.Set(ctx, key, value); err != nil {
+		c.remove(key)
+
+		return err
+	}
+
+	c.add(key, value)
+
+	return nil
+}
+
+// Close closes the backend
+func (c *TieredCache) Close() error {
+	c.mu.Lock()
+	c.ll.Init()
+	clear(c.items)
+	c.bytes = 0
+
+	for _, l := range c.loads {
+		l.stale = true
+	}
+
+	c.mu.Unlock()
+
+	return c.backend.Close()
+}
+
+// Delete removes the entry from memory and from the backend if it
+// implements scrapemate.CacheAdmin
+func (c *TieredCache) Delete(ctx context.Context, key string) error {
+	c.remove(key)
+
+	if admin, ok := c.backend.(scrapemate.CacheAdmin); ok {
+		return admin.Delete(ctx, key)
+	}
+
+	return nil
+}
+
+// Purge removes the entries whose key starts with prefix from memory and
+// from the backend. The count is the one of the backend if it implements
+// scrapemate.CacheAdmin.
+func (c *TieredCache) Purge(ctx context.Context, prefix string) (int, error) {
+	c.mu.Lock()
+
+	var removed int
+
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(el)
+
+			removed++
+		}
+	}
+
+	for key, l := range c.loads {
+		if strings.HasPrefix(key, prefix) {
+			l.stale = true
+		}
+	}
+
+	c.mu.Unlock()
+
+	if admin, ok := c.backend.(scrapemate.CacheAdmin); ok {
+		return admin.Purge(ctx, prefix)
+	}
+
+	return removed, nil
+}
+
+// Iterate iterates the backend entries
+func (c *TieredCache) Iterate(ctx context.Context, fn func(key string, value *scrapemate.Response) error) error {
+	admin, ok := c.backend.(scrapemate.CacheAdmin)
+	if !ok {
+		return scrapemate.ErrCacheNotIterable
+	}
+
+	return admin.Iterate(ctx, fn)
+}
+
+// Stats returns the stats of the backend
+func (c *TieredCache) Stats(ctx context.Context) (scrapemate.CacheStats, error) {
+	admin, ok := c.backend.(scrapemate.CacheAdmin)
+	if !ok {
+		return scrapemate.CacheStats{}, scrapemate.ErrCacheNotIterable
+	}
+
+	return admin.Stats(ctx)
+}
+
+// MemoryStats returns the number of entries and the approximate bytes
+// held in memory
+func (c *TieredCache) MemoryStats() scrapemate.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return scrapemate.CacheStats{Entries: c.ll.Len(), Bytes: c.bytes}
+}
+
+func (c *TieredCache) add(key string, value *scrapemate.Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.invalidateLoad(key)
+	c.addLocked(key, value)
+}
+
+// addLocked keeps value in memory. It must be called with mu held.
+func (c *TieredCache) addLocked(key string, value *scrapemate.Response) {
+	size := sizeOf(key, value)
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+
+	if size > c.maxBytes {
+		return
+	}
+
+	e := &entry{key: key, value: clone(value), size: size}
+	e.value.Document = nil
+
+	c.items[key] = c.ll.PushFront(e)
+	c.bytes += size
+
+	for c.ll.Len() > c.maxEntries || c.bytes > c.maxBytes {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *TieredCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.invalidateLoad(key)
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// invalidateLoad keeps a read of key in progress from putting the value
+// it read in memory. It must be called with mu held.
+func (c *TieredCache) invalidateLoad(key string) {
+	if l, ok := c.loads[key]; ok {
+		l.stale = true
+	}
+}
+
+func (c *TieredCache) removeElement(el *list.Element) {
+	//nolint:errcheck // only *entry values are stored
+	e := c.ll.Remove(el).(*entry)
+
+	delete(c.items, e.key)
+	c.bytes -= e.size
+}
+
+// clone copies the maps of the response so callers can modify them.
+// Body and Screenshot are shared since they are replaced, not modified.
+func clone(value *scrapemate.Response) scrapemate.Response {
+	ans := *value
+	ans.Headers = value.Headers.Clone()
+	ans.Meta = maps.Clone(value.Meta)
+
+	return ans
+}
+
+func sizeOf(key string, value *scrapemate.Response) int64 {
+	const overhead = 256
+
+	size := int64(overhead + len(key) + len(value.URL) + len(value.Body) + len(value.Screenshot))
+
+	for k, values := range value.Headers {
+		size += int64(len(k))
+
+		for _, v := range values {
+			size += int64(len(v))
+		}
+	}
+
+	return size
+}
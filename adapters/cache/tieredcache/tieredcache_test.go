@@ -0,0 +1,168 @@
+package tieredcache_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/cache/tieredcache"
+	"github.com/gosom/scrapemate/mock"
+)
+
+func TestTieredCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("promotes on read", func(t *testing.T) {
+		backend := mock.NewMockCacher(gomock.NewController(t))
+
+		c, err := tieredcache.New(backend)
+		require.NoError(t, err)
+
+		backend.EXPECT().Get(gomock.Any(), "a").Return(scrapemate.Response{
+			StatusCode: 200,
+			Headers:    http.Header{"Etag": []string{"1"}},
+		}, nil).Times(1)
+
+		for range 3 {
+			resp, err := c.Get(ctx, "a")
+			require.NoError(t, err)
+			require.Equal(t, 200, resp.StatusCode)
+
+			// callers may modify the headers of the returned copy
+			resp.Headers.Set("Etag", "2")
+		}
+
+		resp, err := c.Get(ctx, "a")
+		require.NoError(t, err)
+		require.Equal(t, "1", resp.Headers.Get("Etag"))
+	})
+	t.Run("writes through", func(t *testing.T) {
+		backend := mock.NewMockCacher(gomock.NewController(t))
+
+		c, err := tieredcache.New(backend)
+		require.NoError(t, err)
+
+		backend.EXPECT().Set(gomock.Any(), "a", gomock.Any()).Return(nil)
+
+		require.NoError(t, c.Set(ctx, "a", &scrapemate.Response{StatusCode: 201}))
+
+		resp, err := c.Get(ctx, "a")
+		require.NoError(t, err)
+		require.Equal(t, 201, resp.StatusCode)
+
+		backend.EXPECT().Set(gomock.Any(), "a", gomock.Any()).Return(errors.New("disk full"))
+		backend.EXPECT().Get(gomock.Any(), "a").Return(scrapemate.Response{}, errors.New("not found"))
+
+		require.Error(t, c.Set(ctx, "a", &scrapemate.Response{StatusCode: 202}))
+
+		_, err = c.Get(ctx, "a")
+		require.Error(t, err)
+	})
+	t.Run("stamps both tiers alike", func(t *testing.T) {
+		backend := mock.NewMockCacher(gomock.NewController(t))
+
+		c, err := tieredcache.New(backend)
+		require.NoError(t, err)
+
+		var stored time.Time
+
+		backend.EXPECT().Set(gomock.Any(), "a", gomock.Any()).DoAndReturn(func(_ context.Context, _ string, value *scrapemate.Response) error {
+			stored = value.CachedAt
+
+			return nil
+		})
+
+		value := &scrapemate.Response{StatusCode: 200}
+		require.NoError(t, c.Set(ctx, "a", value))
+		require.True(t, value.CachedAt.IsZero(), "the caller's value is not modified")
+		require.False(t, stored.IsZero())
+
+		resp, err := c.Get(ctx, "a")
+		require.NoError(t, err)
+		require.Equal(t, stored, resp.CachedAt)
+	})
+	t.Run("concurrent write during read", func(t *testing.T) {
+		backend := mock.NewMockCacher(gomock.NewController(t))
+
+		c, err := tieredcache.New(backend)
+		require.NoError(t, err)
+
+		reading, release := make(chan struct{}), make(chan struct{})
+
+		backend.EXPECT().Get(gomock.Any(), "a").DoAndReturn(func(context.Context, string) (scrapemate.Response, error) {
+			close(reading)
+			<-release
+
+			return scrapemate.Response{StatusCode: 200}, nil
+		}).Times(1)
+		backend.EXPECT().Set(gomock.Any(), "a", gomock.Any()).Return(nil)
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			_, _ = c.Get(ctx, "a")
+		}()
+
+		<-reading
+		require.NoError(t, c.Set(ctx, "a", &scrapemate.Response{StatusCode: 201}))
+		close(release)
+		<-done
+
+		// the response read before the write is not kept in memory
+		resp, err := c.Get(ctx, "a")
+		require.NoError(t, err)
+		require.Equal(t, 201, resp.StatusCode)
+	})
+	t.Run("evicts by entries", func(t *testing.T) {
+		backend := mock.NewMockCacher(gomock.NewController(t))
+
+		c, err := tieredcache.New(backend, tieredcache.WithMaxEntries(2))
+		require.NoError(t, err)
+
+		backend.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(3)
+
+		for _, key := range []string{"a", "b", "c"} {
+			require.NoError(t, c.Set(ctx, key, &scrapemate.Response{}))
+		}
+
+		require.Equal(t, 2, c.MemoryStats().Entries)
+
+		backend.EXPECT().Get(gomock.Any(), "a").Return(scrapemate.Response{}, nil)
+
+		_, err = c.Get(ctx, "a")
+		require.NoError(t, err)
+	})
+	t.Run("evicts by bytes", func(t *testing.T) {
+		backend := mock.NewMockCacher(gomock.NewController(t))
+
+		c, err := tieredcache.New(backend, tieredcache.WithMaxBytes(1000))
+		require.NoError(t, err)
+
+		backend.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(3)
+
+		require.NoError(t, c.Set(ctx, "a", &scrapemate.Response{Body: make([]byte, 500)}))
+		require.NoError(t, c.Set(ctx, "b", &scrapemate.Response{Body: make([]byte, 500)}))
+		require.Equal(t, 1, c.MemoryStats().Entries)
+
+		require.NoError(t, c.Set(ctx, "big", &scrapemate.Response{Body: make([]byte, 2000)}))
+		require.Equal(t, 1, c.MemoryStats().Entries)
+		require.LessOrEqual(t, c.MemoryStats().Bytes, int64(1000))
+	})
+	t.Run("invalid options", func(t *testing.T) {
+		backend := mock.NewMockCacher(gomock.NewController(t))
+
+		_, err := tieredcache.New(backend, tieredcache.WithMaxEntries(0))
+		require.ErrorIs(t, err, tieredcache.ErrInvalidLimit)
+
+		_, err = tieredcache.New(nil)
+		require.ErrorIs(t, err, scrapemate.ErrorNoCacher)
+	})
+}
@@ -0,0 +1,185 @@
+// Package fetchers contains helpers shared by the HTTP fetcher adapters.
+package fetchers
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/gosom/scrapemate"
+)
+
+// ErrUnsupportedEncoding is returned when a response uses a content coding
+// that cannot be decoded
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// ReadBody reads a response body applying limits.
+// method is the request method, status and header the response status
+// and headers and contentEncoding the encoding the body is compressed
+// with (empty for identity), see NewDecodingReader.
+// The content type is checked before any byte is read when the response
+// has content, see HasContent.
+func ReadBody(
+	body io.Reader, method string, status int, header http.Header, contentEncoding string, limits scrapemate.ResponseLimits,
+) ([]byte, bool, error) {
+	if HasContent(method, status, header) {
+		if err := limits.CheckContentType(header.Get("Content-Type")); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Content-Length refers to the encoded body, so it's a lower bound
+	// of the decoded size and safe to reject early.
+	if cl := header.Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil {
+			if err := limits.CheckContentLength(n); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	reader, err := NewDecodingReader(body, contentEncoding)
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer reader.Close()
+
+	return limits.ReadBody(reader)
+}
+
+// HasContent reports whether a response with status and header to a
+// method request has content. The content type allowlist doesn't apply
+// to the ones without, like answers to HEAD requests, 304 Not Modified
+// answers to revalidations and responses without a body or a
+// Content-Type.
+func HasContent(method string, status int, header http.Header) bool {
+	switch {
+	case method == http.MethodHead,
+		status >= 100 && status < 200,
+		status == http.StatusNoContent,
+		status == http.StatusNotModified,
+		header.Get("Content-Type") == "",
+		header.Get("Content-Length") == "0":
+		return false
+	default:
+		return true
+	}
+}
+
+// ContentEncoding returns the codings listed in the Content-Encoding
+// header(s) joined with commas, in the order they were applied.
+func ContentEncoding(header http.Header) string {
+	return strings.Join(header.Values("Content-Encoding"), ",")
+}
+
+// StripEncodingHeaders removes the Content-Encoding header from a response
+// whose body has been decoded and sets Content-Length to the decoded size,
+// so that the stored response is consistent with its body.
+// It returns the removed encoding, if any.
+func StripEncodingHeaders(header http.Header, bodyLen int) string {
+	encoding := ContentEncoding(header)
+
+	header.Del("Content-Encoding")
+
+	if encoding != "" || header.Get("Content-Length") != "" {
+		header.Set("Content-Length", strconv.Itoa(bodyLen))
+	}
+
+	return encoding
+}
+
+// NewDecodingReader returns a reader that decodes body according
+// to contentEncoding. Supported codings are gzip, br, deflate and zstd.
+// contentEncoding may list several comma separated codings, in the order
+// they were applied by the server; they are undone in reverse order.
+func NewDecodingReader(body io.Reader, contentEncoding string) (io.ReadCloser, error) {
+	codings := strings.Split(contentEncoding, ",")
+
+	reader := &stackedReader{Reader: body}
+
+	for i := len(codings) - 1; i >= 0; i-- {
+		decoder, err := newDecoder(reader.Reader, codings[i])
+		if err != nil {
+			_ = reader.Close()
+
+			return nil, err
+		}
+
+		if decoder == nil {
+			continue
+		}
+
+		reader.Reader = decoder
+		reader.closers = append(reader.closers, decoder)
+	}
+
+	return reader, nil
+}
+
+type stackedReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *stackedReader) Close() error {
+	var errs []error
+
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		errs = append(errs, r.closers[i].Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// newDecoder returns nil for the identity coding
+func newDecoder(body io.Reader, coding string) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(coding)) {
+	case "", "identity":
+		return nil, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "br":
+		return io.NopCloser(brotli.NewReader(body)), nil
+	case "deflate":
+		return newDeflateReader(body)
+	case "zstd":
+		dec, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+
+		return dec.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, coding)
+	}
+}
+
+// newDeflateReader handles both zlib wrapped (RFC 1950) and raw (RFC 1951)
+// deflate streams since servers use both for Content-Encoding: deflate.
+func newDeflateReader(body io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(body)
+
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	const zlibDeflateMethod = 0x08
+
+	if len(header) == 2 && header[0]&0x0f == zlibDeflateMethod && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
@@ -48,6 +48,10 @@ type JSFetcherOptions struct {
 	PageReuseLimit     int
 	BrowserReuseLimit  int
 	UserAgent          string
+	// ResponseLimits are applied to every resource the browser loads.
+	// Resources exceeding them are aborted. The browser downloads bodies
+	// whole, so MaxBodySize doesn't reduce memory use.
+	ResponseLimits scrapemate.ResponseLimits
 }
 
 func New(params JSFetcherOptions) (scrapemate.HTTPFetcher, error) {
@@ -95,6 +99,7 @@ func New(params JSFetcherOptions) (scrapemate.HTTPFetcher, error) {
 		proxyPool:          pool,
 		rotator:            params.Rotator,
 		maxPagesPerBrowser: maxPagesPerBrowser,
+		limits:             params.ResponseLimits,
 	}
 
 	if maxPagesPerBrowser > 1 {
@@ -150,6 +155,7 @@ type jsFetch struct {
 
 	rotator            scrapemate.ProxyRotator
 	maxPagesPerBrowser int
+	limits             scrapemate.ResponseLimits
 }
 
 func (o *jsFetch) getSlot(ctx context.Context) (*sessionSlot, error) {
@@ -225,8 +231,17 @@ func (o *jsFetch) Fetch(ctx context.Context, job scrapemate.IJob) scrapemate.Res
 		pp.playwrightPage().SetDefaultTimeout(float64(job.GetTimeout().Milliseconds()))
 	}
 
+	removeLimits, err := limitResources(pp.playwrightPage(), scrapemate.ResponseLimitsForJob(o.limits, job))
+	if err != nil {
+		return scrapemate.Response{
+			Error: err,
+		}
+	}
+
 	resp := runBrowserActions(ctx, job, playwrightadapter.NewPage(pp.playwrightPage()))
 
+	removeLimits()
+
 	if cleanErr := slot.release(ctx); cleanErr != nil && resp.Error == nil {
 		resp.Error = cleanErr
 	}
@@ -264,6 +279,13 @@ func (o *jsFetch) fetchWithPageSlot(ctx context.Context, job scrapemate.IJob) sc
 	lease.slot.browserUsage++
 	lease.slot.mu.Unlock()
 
+	removeLimits, err := limitResources(page, scrapemate.ResponseLimitsForJob(o.limits, job))
+	if err != nil {
+		return scrapemate.Response{Error: err}
+	}
+
+	defer removeLimits()
+
 	return runBrowserActions(ctx, job, playwrightadapter.NewPage(page))
 }
 
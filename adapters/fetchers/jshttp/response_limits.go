@@ -0,0 +1,88 @@
+package jshttp
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/playwright-community/playwright-go"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/fetchers"
+)
+
+const routeAllPattern = "**/*"
+
+// limitResources routes every request of the page through a handler that
+// aborts resources exceeding limits. The content type allowlist only applies
+// to navigation requests since subresources (scripts, images, css) would
+// otherwise be blocked. It returns a function that removes the route.
+func limitResources(page playwright.Page, limits scrapemate.ResponseLimits) (func(), error) {
+	if limits.IsZero() {
+		return func() {}, nil
+	}
+
+	handler := func(route playwright.Route) {
+		_ = handleLimitedRoute(route, limits)
+	}
+
+	if err := page.Route(routeAllPattern, handler); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = page.Unroute(routeAllPattern, handler)
+	}, nil
+}
+
+// handleLimitedRoute fetches the resource of route and fulfills or aborts
+// it according to limits. Playwright downloads the whole body before the
+// route can inspect it, so MaxBodySize keeps large bodies away from the
+// page but doesn't bound memory. A Content-Length above the limit aborts
+// the resource before its body is copied into this process.
+func handleLimitedRoute(route playwright.Route, limits scrapemate.ResponseLimits) error {
+	const abortReason = "blockedbyclient"
+
+	resp, err := route.Fetch()
+	if err != nil {
+		return route.Abort("failed")
+	}
+
+	header := make(http.Header, len(resp.Headers()))
+	for k, v := range resp.Headers() {
+		header.Set(k, v)
+	}
+
+	if route.Request().IsNavigationRequest() && fetchers.HasContent(route.Request().Method(), resp.Status(), header) {
+		if err := limits.CheckContentType(header.Get("Content-Type")); err != nil {
+			return route.Abort(abortReason)
+		}
+	}
+
+	if limits.MaxBodySize <= 0 {
+		return route.Fulfill(playwright.RouteFulfillOptions{Response: resp})
+	}
+
+	if cl, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		if err := limits.CheckContentLength(cl); err != nil {
+			return route.Abort(abortReason)
+		}
+	}
+
+	body, err := resp.Body()
+	if err != nil {
+		return route.Abort("failed")
+	}
+
+	if int64(len(body)) <= limits.MaxBodySize {
+		return route.Fulfill(playwright.RouteFulfillOptions{Response: resp})
+	}
+
+	if !limits.TruncateBody {
+		return route.Abort(abortReason)
+	}
+
+	return route.Fulfill(playwright.RouteFulfillOptions{
+		Response: resp,
+		Body:     body[:limits.MaxBodySize],
+	})
+}
@@ -2,13 +2,13 @@ package nethttp
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
 	"io"
 	"net/http"
 	"sync"
 
 	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/fetchers"
 )
 
 var _ scrapemate.HTTPFetcher = (*httpFetch)(nil)
@@ -17,14 +17,32 @@ type HTTPClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
-func New(netClient HTTPClient) scrapemate.HTTPFetcher {
-	return &httpFetch{
+// Option configures the fetcher
+type Option func(*httpFetch)
+
+// WithResponseLimits sets the global response limits of the fetcher.
+// Jobs implementing scrapemate.ResponseLimiter may override them.
+func WithResponseLimits(limits scrapemate.ResponseLimits) Option {
+	return func(o *httpFetch) {
+		o.limits = limits
+	}
+}
+
+func New(netClient HTTPClient, options ...Option) scrapemate.HTTPFetcher {
+	ans := &httpFetch{
 		netClient: netClient,
 	}
+
+	for _, opt := range options {
+		opt(ans)
+	}
+
+	return ans
 }
 
 type httpFetch struct {
 	netClient HTTPClient
+	limits    scrapemate.ResponseLimits
 }
 
 func (o *httpFetch) Close() error {
@@ -53,6 +71,10 @@ func (o *httpFetch) Fetch(ctx context.Context, job scrapemate.IJob) scrapemate.R
 		req.Header.Add(k, v)
 	}
 
+	for k, v := range scrapemate.RequestHeadersFromContext(ctx) {
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+
 	resp, err := o.netClient.Do(req)
 	if err != nil {
 		ans.Error = err
@@ -60,7 +82,11 @@ func (o *httpFetch) Fetch(ctx context.Context, job scrapemate.IJob) scrapemate.R
 	}
 
 	defer func() {
-		_, _ = io.Copy(io.Discard, resp.Body)
+		// drain a bounded amount so the connection can be reused without
+		// downloading the rest of a body we rejected because of the limits
+		const maxDrain = 64 << 10
+
+		_, _ = io.CopyN(io.Discard, resp.Body, maxDrain)
 		resp.Body.Close()
 	}()
 
@@ -71,22 +97,22 @@ func (o *httpFetch) Fetch(ctx context.Context, job scrapemate.IJob) scrapemate.R
 		ans.Headers[k] = v
 	}
 
-	var reader io.ReadCloser
+	ans.URL = resp.Request.URL.String()
 
-	switch resp.Header.Get("Content-Encoding") {
-	case "gzip":
-		reader, err = gzip.NewReader(resp.Body)
-		if err != nil {
-			ans.Error = err
-			return ans
-		}
-		defer reader.Close()
-	default:
-		reader = resp.Body
+	limits := scrapemate.ResponseLimitsForJob(o.limits, job)
+
+	ans.Body, ans.Truncated, ans.Error = fetchers.ReadBody(
+		resp.Body, resp.Request.Method, resp.StatusCode, resp.Header, fetchers.ContentEncoding(resp.Header), limits,
+	)
+	if ans.Error != nil {
+		return ans
 	}
 
-	ans.Body, ans.Error = io.ReadAll(reader)
-	ans.URL = resp.Request.URL.String()
+	if encoding := fetchers.StripEncodingHeaders(ans.Headers, len(ans.Body)); encoding != "" {
+		ans.Meta = map[string]any{
+			scrapemate.MetaContentEncoding: encoding,
+		}
+	}
 
 	return ans
 }
@@ -0,0 +1,228 @@
+package nethttp_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/fetchers/nethttp"
+)
+
+const testBody = "<html><body>hello world</body></html>"
+
+func encode(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+		err error
+	)
+
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "zstd":
+		w, err = zstd.NewWriter(&buf)
+	}
+
+	require.NoError(t, err)
+
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func newServer(t *testing.T, contentType string, codings ...string) *httptest.Server {
+	t.Helper()
+
+	body := []byte(testBody)
+	headerCodings := make([]string, 0, len(codings))
+
+	for _, coding := range codings {
+		body = encode(t, coding, body)
+
+		if coding == "raw-deflate" {
+			coding = "deflate"
+		}
+
+		headerCodings = append(headerCodings, coding)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+
+		if len(headerCodings) > 0 {
+			w.Header().Set("Content-Encoding", strings.Join(headerCodings, ", "))
+		}
+
+		_, _ = w.Write(body)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestFetch_ContentEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		codings []string
+	}{
+		{name: "identity"},
+		{name: "gzip", codings: []string{"gzip"}},
+		{name: "br", codings: []string{"br"}},
+		{name: "deflate", codings: []string{"deflate"}},
+		{name: "raw deflate", codings: []string{"raw-deflate"}},
+		{name: "zstd", codings: []string{"zstd"}},
+		{name: "stacked", codings: []string{"gzip", "br"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newServer(t, "text/html", tc.codings...)
+			fetcher := nethttp.New(&http.Client{})
+
+			job := &scrapemate.Job{
+				Method: http.MethodGet,
+				URL:    srv.URL,
+				// setting the header ourselves disables the transparent
+				// gzip decoding of net/http
+				Headers: map[string]string{"Accept-Encoding": "gzip, deflate, br, zstd"},
+			}
+
+			resp := fetcher.Fetch(context.Background(), job)
+			require.NoError(t, resp.Error)
+			require.Equal(t, testBody, string(resp.Body))
+			require.Empty(t, resp.Headers.Get("Content-Encoding"))
+			require.Equal(t, "37", resp.Headers.Get("Content-Length"))
+
+			if len(tc.codings) > 0 {
+				require.NotEmpty(t, resp.Meta[scrapemate.MetaContentEncoding])
+			}
+		})
+	}
+}
+
+func TestFetch_ResponseLimits(t *testing.T) {
+	srv := newServer(t, "text/html; charset=utf-8", "gzip")
+
+	t.Run("content type not allowed", func(t *testing.T) {
+		fetcher := nethttp.New(&http.Client{}, nethttp.WithResponseLimits(scrapemate.ResponseLimits{
+			AllowedContentTypes: []string{"application/json"},
+		}))
+
+		resp := fetcher.Fetch(context.Background(), &scrapemate.Job{Method: http.MethodGet, URL: srv.URL})
+		require.ErrorIs(t, resp.Error, scrapemate.ErrContentTypeNotAllowed)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+	t.Run("body too large", func(t *testing.T) {
+		fetcher := nethttp.New(&http.Client{}, nethttp.WithResponseLimits(scrapemate.ResponseLimits{
+			MaxBodySize: 10,
+		}))
+
+		resp := fetcher.Fetch(context.Background(), &scrapemate.Job{Method: http.MethodGet, URL: srv.URL})
+		require.ErrorIs(t, resp.Error, scrapemate.ErrBodyTooLarge)
+	})
+	t.Run("job truncates", func(t *testing.T) {
+		fetcher := nethttp.New(&http.Client{}, nethttp.WithResponseLimits(scrapemate.ResponseLimits{
+			MaxBodySize: 10,
+		}))
+
+		resp := fetcher.Fetch(context.Background(), &scrapemate.Job{
+			Method:         http.MethodGet,
+			URL:            srv.URL,
+			ResponseLimits: scrapemate.ResponseLimits{MaxBodySize: 6, TruncateBody: true},
+		})
+		require.NoError(t, resp.Error)
+		require.True(t, resp.Truncated)
+		require.Equal(t, "<html>", string(resp.Body))
+	})
+	t.Run("responses without content", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Header.Get("If-None-Match") == `"v1"`:
+				w.Header().Set("Etag", `"v1"`)
+				w.WriteHeader(http.StatusNotModified)
+			case r.URL.Path == "/empty":
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.Header().Set("Content-Type", "video/mp4")
+				_, _ = w.Write([]byte("video"))
+			}
+		}))
+		t.Cleanup(srv.Close)
+
+		fetcher := nethttp.New(&http.Client{}, nethttp.WithResponseLimits(scrapemate.ResponseLimits{
+			AllowedContentTypes: []string{"text/html"},
+		}))
+
+		// revalidations answered with 304 Not Modified
+		ctx := scrapemate.ContextWithRequestHeaders(context.Background(), http.Header{
+			"If-None-Match": []string{`"v1"`},
+		})
+
+		resp := fetcher.Fetch(ctx, &scrapemate.Job{Method: http.MethodGet, URL: srv.URL})
+		require.NoError(t, resp.Error)
+		require.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+		resp = fetcher.Fetch(context.Background(), &scrapemate.Job{Method: http.MethodGet, URL: srv.URL + "/empty"})
+		require.NoError(t, resp.Error)
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		resp = fetcher.Fetch(context.Background(), &scrapemate.Job{Method: http.MethodHead, URL: srv.URL})
+		require.NoError(t, resp.Error)
+
+		resp = fetcher.Fetch(context.Background(), &scrapemate.Job{Method: http.MethodGet, URL: srv.URL})
+		require.ErrorIs(t, resp.Error, scrapemate.ErrContentTypeNotAllowed)
+	})
+}
+
+func TestFetch_RequestHeadersFromContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(testBody))
+	}))
+	t.Cleanup(srv.Close)
+
+	fetcher := nethttp.New(&http.Client{})
+	job := &scrapemate.Job{Method: http.MethodGet, URL: srv.URL}
+
+	ctx := scrapemate.ContextWithRequestHeaders(context.Background(), http.Header{
+		"If-None-Match": []string{`"v1"`},
+	})
+
+	resp := fetcher.Fetch(ctx, job)
+	require.NoError(t, resp.Error)
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+	require.Empty(t, resp.Body)
+
+	resp = fetcher.Fetch(context.Background(), job)
+	require.NoError(t, resp.Error)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
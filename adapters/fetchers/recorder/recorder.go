@@ -0,0 +1,266 @@
+// Package recorder provides an HTTPFetcher decorator that records the
+// responses of another fetcher to fixture files and replays them, for
+// deterministic cassette style tests of whole crawls.
+//
+//	fetcher, err := recorder.New(nethttp.New(client), "testdata/fixtures", recorder.ModeRecord)
+//
+// Once recorded, tests replay the fixtures without network or browser:
+//
+//	fetcher, err := recorder.New(nil, "testdata/fixtures", recorder.ModeReplay)
+package recorder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gosom/scrapemate"
+)
+
+var _ scrapemate.HTTPFetcher = (*Fetcher)(nil)
+
+// Mode is the mode of a Fetcher
+type Mode int
+
+const (
+	// ModePassthrough calls the inner fetcher without recording
+	ModePassthrough Mode = iota
+	// ModeRecord calls the inner fetcher and saves every response
+	ModeRecord
+	// ModeReplay serves the saved responses and never calls the inner fetcher
+	ModeReplay
+)
+
+var (
+	// ErrFixtureNotFound is the response error in replay mode for
+	// requests that were not recorded
+	ErrFixtureNotFound = errors.New("no recorded response")
+	// ErrInvalidMode returned for unknown modes
+	ErrInvalidMode = errors.New("invalid recorder mode")
+)
+
+// ParseMode parses "passthrough", "record" or "replay", e.g. from an
+// environment variable
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "passthrough", "":
+		return ModePassthrough, nil
+	case "record":
+		return ModeRecord, nil
+	case "replay":
+		return ModeReplay, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMode, s)
+	}
+}
+
+// Fetcher records and replays the responses of an inner fetcher.
+// Fixtures are keyed by the method, full URL and body of the jobs.
+type Fetcher struct {
+	inner scrapemate.HTTPFetcher
+	dir   string
+	mode  Mode
+}
+
+// New creates a Fetcher storing fixtures in dir.
+// inner may be nil in replay mode.
+func New(inner scrapemate.HTTPFetcher, dir string, mode Mode) (*Fetcher, error) {
+	switch mode {
+	case ModePassthrough, ModeRecord:
+		if inner == nil {
+			return nil, scrapemate.ErrorNoHTMLFetcher
+		}
+	case ModeReplay:
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrInvalidMode, mode)
+	}
+
+	if mode == ModeRecord {
+		const permissions = 0o777
+		if err := os.MkdirAll(dir, permissions); err != nil {
+			return nil, fmt.Errorf("cannot create fixtures dir %w", err)
+		}
+	}
+
+	return &Fetcher{inner: inner, dir: dir, mode: mode}, nil
+}
+
+// Fetch returns the recorded response in replay mode or fetches the job
+// with the inner fetcher, recording the response in record mode
+func (f *Fetcher) Fetch(ctx context.Context, job scrapemate.IJob) scrapemate.Response {
+	switch f.mode {
+	case ModeReplay:
+		resp, err := f.replay(job)
+		if err != nil {
+			scrapemate.GetLoggerFromContext(ctx).Error("cannot replay response", "error", err)
+
+			return scrapemate.Response{Error: err}
+		}
+
+		return resp
+	case ModeRecord:
+		resp := f.inner.Fetch(ctx, job)
+
+		if err := f.record(job, &resp); err != nil {
+			scrapemate.GetLoggerFromContext(ctx).Error("cannot record response", "error", err)
+		}
+
+		return resp
+	default:
+		return f.inner.Fetch(ctx, job)
+	}
+}
+
+// Close closes the inner fetcher
+func (f *Fetcher) Close() error {
+	if f.inner == nil {
+		return nil
+	}
+
+	return f.inner.Close()
+}
+
+// fixture is the file format of a recorded response.
+// Text bodies are stored as strings to keep fixtures readable and
+// diffable, binary ones base64 encoded.
+type fixture struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	RequestBody string         `json:"request_body,omitempty"`
+	ResponseURL string         `json:"response_url,omitempty"`
+	StatusCode  int            `json:"status_code"`
+	Headers     http.Header    `json:"headers,omitempty"`
+	Body        string         `json:"body,omitempty"`
+	BinaryBody  []byte         `json:"binary_body,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	Duration    time.Duration  `json:"duration,omitempty"`
+	Meta        map[string]any `json:"meta,omitempty"`
+	Screenshot  []byte         `json:"screenshot,omitempty"`
+	Truncated   bool           `json:"truncated,omitempty"`
+}
+
+func (f *Fetcher) record(job scrapemate.IJob, resp *scrapemate.Response) error {
+	fx := fixture{
+		Method:      method(job),
+		URL:         job.GetFullURL(),
+		RequestBody: string(job.GetBody()),
+		ResponseURL: resp.URL,
+		StatusCode:  resp.StatusCode,
+		Headers:     resp.Headers,
+		Duration:    resp.Duration,
+		Meta:        resp.Meta,
+		Screenshot:  resp.Screenshot,
+		Truncated:   resp.Truncated,
+	}
+
+	if utf8.Valid(resp.Body) {
+		fx.Body = string(resp.Body)
+	} else {
+		fx.BinaryBody = resp.Body
+	}
+
+	if resp.Error != nil {
+		fx.Error = resp.Error.Error()
+	}
+
+	data, err := json.MarshalIndent(&fx, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(f.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	// fixtures are committed with the tests, CreateTemp makes them 0600
+	const filePermissions = 0o644
+
+	if err := tmp.Chmod(filePermissions); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path(job))
+}
+
+func (f *Fetcher) replay(job scrapemate.IJob) (scrapemate.Response, error) {
+	data, err := os.ReadFile(f.path(job))
+	if errors.Is(err, fs.ErrNotExist) {
+		return scrapemate.Response{}, fmt.Errorf("%w for %s %s", ErrFixtureNotFound, method(job), job.GetFullURL())
+	}
+
+	if err != nil {
+		return scrapemate.Response{}, err
+	}
+
+	var fx fixture
+
+	if err := json.Unmarshal(data, &fx); err != nil {
+		return scrapemate.Response{}, fmt.Errorf("invalid fixture %s: %w", f.path(job), err)
+	}
+
+	resp := scrapemate.Response{
+		URL:        fx.ResponseURL,
+		StatusCode: fx.StatusCode,
+		Headers:    fx.Headers,
+		Duration:   fx.Duration,
+		Meta:       fx.Meta,
+		Screenshot: fx.Screenshot,
+		Truncated:  fx.Truncated,
+	}
+
+	switch {
+	case fx.BinaryBody != nil:
+		resp.Body = fx.BinaryBody
+	case fx.Body != "":
+		resp.Body = []byte(fx.Body)
+	}
+
+	if fx.Error != "" {
+		resp.Error = errors.New(fx.Error)
+	}
+
+	return resp, nil
+}
+
+// path returns the fixture file of job
+func (f *Fetcher) path(job scrapemate.IJob) string {
+	h := sha256.New()
+	h.Write([]byte(method(job) + "\n" + job.GetFullURL() + "\n"))
+	h.Write(job.GetBody())
+
+	return filepath.Join(f.dir, hex.EncodeToString(h.Sum(nil)[:16])+".json")
+}
+
+func method(job scrapemate.IJob) string {
+	if m := job.GetMethod(); m != "" {
+		return m
+	}
+
+	return http.MethodGet
+}
@@ -0,0 +1,191 @@
+package recorder_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/fetchers/recorder"
+	"github.com/gosom/scrapemate/mock"
+)
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	get := &scrapemate.Job{Method: http.MethodGet, URL: "http://example.com", URLParams: map[string]string{"page": "1"}}
+	post := &scrapemate.Job{Method: http.MethodPost, URL: "http://example.com", Body: []byte("a=1")}
+	failing := &scrapemate.Job{Method: http.MethodGet, URL: "http://example.com/error"}
+
+	responses := map[*scrapemate.Job]scrapemate.Response{
+		get: {
+			URL:        "http://example.com?page=1",
+			StatusCode: http.StatusOK,
+			Headers:    http.Header{"Content-Type": []string{"text/html"}},
+			Body:       []byte("<html>get</html>"),
+			Screenshot: []byte{0x89, 'P', 'N', 'G'},
+		},
+		post: {
+			StatusCode: http.StatusOK,
+			Body:       []byte{0xff, 0xfe, 0x00},
+		},
+		failing: {
+			StatusCode: http.StatusBadGateway,
+			Error:      errors.New("status code 502"),
+		},
+	}
+
+	t.Run("record", func(t *testing.T) {
+		inner := mock.NewMockHTTPFetcher(gomock.NewController(t))
+
+		fetcher, err := recorder.New(inner, dir, recorder.ModeRecord)
+		require.NoError(t, err)
+
+		for job, resp := range responses {
+			inner.EXPECT().Fetch(gomock.Any(), job).Return(resp)
+
+			require.Equal(t, resp, fetcher.Fetch(ctx, job))
+		}
+	})
+	t.Run("fixture permissions", func(t *testing.T) {
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		require.NoError(t, err)
+		require.Len(t, files, len(responses))
+
+		for _, file := range files {
+			info, err := os.Stat(file)
+			require.NoError(t, err)
+			require.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+		}
+	})
+	t.Run("replay", func(t *testing.T) {
+		fetcher, err := recorder.New(nil, dir, recorder.ModeReplay)
+		require.NoError(t, err)
+
+		for job, want := range responses {
+			got := fetcher.Fetch(ctx, job)
+
+			if want.Error != nil {
+				require.EqualError(t, got.Error, want.Error.Error())
+
+				got.Error = want.Error
+			}
+
+			require.Equal(t, want, got)
+		}
+
+		unknown := fetcher.Fetch(ctx, &scrapemate.Job{Method: http.MethodGet, URL: "http://example.com/unknown"})
+		require.ErrorIs(t, unknown.Error, recorder.ErrFixtureNotFound)
+		require.NoError(t, fetcher.Close())
+	})
+	t.Run("passthrough", func(t *testing.T) {
+		inner := mock.NewMockHTTPFetcher(gomock.NewController(t))
+
+		fetcher, err := recorder.New(inner, t.TempDir(), recorder.ModePassthrough)
+		require.NoError(t, err)
+
+		inner.EXPECT().Fetch(gomock.Any(), get).Return(scrapemate.Response{StatusCode: http.StatusNoContent})
+		require.Equal(t, http.StatusNoContent, fetcher.Fetch(ctx, get).StatusCode)
+
+		_, err = recorder.New(nil, dir, recorder.ModeRecord)
+		require.ErrorIs(t, err, scrapemate.ErrorNoHTMLFetcher)
+	})
+	t.Run("parse mode", func(t *testing.T) {
+		mode, err := recorder.ParseMode("replay")
+		require.NoError(t, err)
+		require.Equal(t, recorder.ModeReplay, mode)
+
+		_, err = recorder.ParseMode("rewind")
+		require.ErrorIs(t, err, recorder.ErrInvalidMode)
+	})
+}
+
+// titlePage is a browser page serving a fixed document. Calling the
+// methods it does not implement panics.
+type titlePage struct {
+	scrapemate.BrowserPage
+	visits int
+}
+
+func (p *titlePage) Goto(url string, _ scrapemate.WaitUntilState) (*scrapemate.PageResponse, error) {
+	p.visits++
+
+	return &scrapemate.PageResponse{
+		URL:        url,
+		StatusCode: http.StatusOK,
+		Headers:    http.Header{"Content-Type": []string{"text/html"}},
+		Body:       []byte("<title>quotes</title>"),
+	}, nil
+}
+
+func (p *titlePage) Eval(string, ...any) (any, error) {
+	return "quotes", nil
+}
+
+func (p *titlePage) Screenshot(bool) ([]byte, error) {
+	return []byte{0x89, 'P', 'N', 'G'}, nil
+}
+
+// browserJob stores the page title in the response meta
+type browserJob struct {
+	scrapemate.Job
+}
+
+func (j *browserJob) BrowserActions(_ context.Context, page scrapemate.BrowserPage) scrapemate.Response {
+	pageResponse, err := page.Goto(j.GetFullURL(), scrapemate.WaitUntilNetworkIdle)
+	if err != nil {
+		return scrapemate.Response{Error: err}
+	}
+
+	title, err := page.Eval("() => document.title")
+	if err != nil {
+		return scrapemate.Response{Error: err}
+	}
+
+	screenshot, err := page.Screenshot(true)
+	if err != nil {
+		return scrapemate.Response{Error: err}
+	}
+
+	return scrapemate.Response{
+		URL:        pageResponse.URL,
+		StatusCode: pageResponse.StatusCode,
+		Headers:    pageResponse.Headers,
+		Body:       pageResponse.Body,
+		Meta:       map[string]any{"title": title},
+		Screenshot: screenshot,
+	}
+}
+
+func TestRecorder_BrowserActions(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	page := &titlePage{}
+	job := &browserJob{Job: scrapemate.Job{Method: http.MethodGet, URL: "http://example.com/js"}}
+
+	// the inner fetcher runs the browser actions like the JS fetcher does
+	inner := mock.NewMockHTTPFetcher(gomock.NewController(t))
+	inner.EXPECT().Fetch(gomock.Any(), job).DoAndReturn(func(ctx context.Context, job scrapemate.IJob) scrapemate.Response {
+		return job.BrowserActions(ctx, page)
+	})
+
+	fetcher, err := recorder.New(inner, dir, recorder.ModeRecord)
+	require.NoError(t, err)
+
+	recorded := fetcher.Fetch(ctx, job)
+	require.NoError(t, recorded.Error)
+	require.Equal(t, "quotes", recorded.Meta["title"])
+
+	fetcher, err = recorder.New(nil, dir, recorder.ModeReplay)
+	require.NoError(t, err)
+
+	require.Equal(t, recorded, fetcher.Fetch(ctx, job))
+	require.Equal(t, 1, page.visits)
+}
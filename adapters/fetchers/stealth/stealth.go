@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/fetchers"
 
 	"github.com/Noooste/azuretls-client"
 )
@@ -18,9 +19,21 @@ type proxyFetcher interface {
 type stealthFetch struct {
 	browserSettings settings
 	rotator         proxyFetcher
+	limits          scrapemate.ResponseLimits
 }
 
-func New(browser string, rotator proxyFetcher) scrapemate.HTTPFetcher {
+// Option configures the fetcher
+type Option func(*stealthFetch)
+
+// WithResponseLimits sets the global response limits of the fetcher.
+// Jobs implementing scrapemate.ResponseLimiter may override them.
+func WithResponseLimits(limits scrapemate.ResponseLimits) Option {
+	return func(o *stealthFetch) {
+		o.limits = limits
+	}
+}
+
+func New(browser string, rotator proxyFetcher, options ...Option) scrapemate.HTTPFetcher {
 	ans := stealthFetch{}
 
 	if browser != "" {
@@ -31,6 +44,10 @@ func New(browser string, rotator proxyFetcher) scrapemate.HTTPFetcher {
 		ans.rotator = rotator
 	}
 
+	for _, opt := range options {
+		opt(&ans)
+	}
+
 	return &ans
 }
 
@@ -39,6 +56,14 @@ func (o *stealthFetch) Close() error {
 }
 
 func (o *stealthFetch) Fetch(ctx context.Context, job scrapemate.IJob) scrapemate.Response {
+	// azuretls ignores TimeOut when it doesn't read the body itself
+	if timeout := job.GetTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	u := job.GetFullURL()
 	reqBody := getBuffer()
 
@@ -66,10 +91,22 @@ func (o *stealthFetch) Fetch(ctx context.Context, job scrapemate.IJob) scrapemat
 	session.Browser = o.browserSettings.browser
 	session.OrderedHeaders = o.browserSettings.headers
 
+	limits := scrapemate.ResponseLimitsForJob(o.limits, job)
+
 	req := azuretls.Request{
 		Method:  job.GetMethod(),
 		Url:     u,
 		TimeOut: job.GetTimeout(),
+		// when we have limits we read the body ourselves so we can stop early
+		IgnoreBody: !limits.IsZero(),
+	}
+
+	if extra := scrapemate.RequestHeadersFromContext(ctx); len(extra) > 0 {
+		req.OrderedHeaders = session.OrderedHeaders.Clone()
+
+		for k, v := range extra {
+			req.OrderedHeaders.Set(k, v...)
+		}
 	}
 
 	req.SetContext(ctx)
@@ -90,9 +127,30 @@ func (o *stealthFetch) Fetch(ctx context.Context, job scrapemate.IJob) scrapemat
 		ans.Headers[k] = v
 	}
 
-	ans.Body = resp.Body
 	ans.URL = u
 
+	if req.IgnoreBody {
+		defer func() {
+			_ = resp.CloseBody()
+		}()
+
+		ans.Body, ans.Truncated, ans.Error = fetchers.ReadBody(
+			resp.RawBody, req.Method, resp.StatusCode, ans.Headers, fetchers.ContentEncoding(ans.Headers), limits,
+		)
+		if ans.Error != nil {
+			return ans
+		}
+	} else {
+		// azuretls has already decoded the body
+		ans.Body = resp.Body
+	}
+
+	if encoding := fetchers.StripEncodingHeaders(ans.Headers, len(ans.Body)); encoding != "" {
+		ans.Meta = map[string]any{
+			scrapemate.MetaContentEncoding: encoding,
+		}
+	}
+
 	return ans
 }
 
@@ -0,0 +1,46 @@
+package jsonparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+var errInvalidJSON = errors.New("invalid json")
+
+// JSONParser decodes JSON response bodies
+type JSONParser struct {
+	raw bool
+}
+
+// New returns a parser whose document is the decoded value of the body:
+// map[string]any for objects, []any for arrays and so on.
+func New() *JSONParser {
+	return &JSONParser{}
+}
+
+// NewRaw returns a parser whose document is the body as a json.RawMessage.
+// The body is validated but not decoded, which is cheaper when the job
+// unmarshals it into its own types.
+func NewRaw() *JSONParser {
+	return &JSONParser{raw: true}
+}
+
+func (p *JSONParser) Parse(_ context.Context, body []byte) (any, error) {
+	if p.raw {
+		if !json.Valid(body) {
+			return nil, errInvalidJSON
+		}
+
+		return json.RawMessage(body), nil
+	}
+
+	var doc any
+
+	if err := json.Unmarshal(bytes.TrimSpace(body), &doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,126 @@
+package xmlparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Node is an element of a parsed XML document.
+// The document returned by the parser is a root Node with an empty Name
+// whose Children are the top level elements.
+type Node struct {
+	Name     xml.Name
+	Attrs    []xml.Attr
+	Parent   *Node
+	Children []*Node
+	// Text is the character data directly inside the element
+	Text string
+}
+
+// Attr returns the value of the attribute with the given local name
+func (n *Node) Attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+// InnerText returns the character data of the element and all its descendants
+func (n *Node) InnerText() string {
+	var sb strings.Builder
+
+	n.walk(func(node *Node) {
+		sb.WriteString(node.Text)
+	})
+
+	return sb.String()
+}
+
+// Find returns all descendant elements with the given local name
+func (n *Node) Find(name string) []*Node {
+	var ans []*Node
+
+	for _, child := range n.Children {
+		child.walk(func(node *Node) {
+			if node.Name.Local == name {
+				ans = append(ans, node)
+			}
+		})
+	}
+
+	return ans
+}
+
+// FindOne returns the first descendant element with the given local name
+// or nil
+func (n *Node) FindOne(name string) *Node {
+	if found := n.Find(name); len(found) > 0 {
+		return found[0]
+	}
+
+	return nil
+}
+
+func (n *Node) walk(fn func(*Node)) {
+	fn(n)
+
+	for _, child := range n.Children {
+		child.walk(fn)
+	}
+}
+
+// XMLParser parses XML response bodies into a tree of *Node
+type XMLParser struct {
+}
+
+func New() *XMLParser {
+	return &XMLParser{}
+}
+
+func (p *XMLParser) Parse(_ context.Context, body []byte) (any, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = charset.NewReaderLabel
+
+	root := &Node{}
+	current := root
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			node := &Node{
+				Name:   t.Name,
+				Attrs:  t.Copy().Attr,
+				Parent: current,
+			}
+
+			current.Children = append(current.Children, node)
+			current = node
+		case xml.EndElement:
+			current = current.Parent
+		case xml.CharData:
+			if current != root {
+				current.Text += string(t)
+			}
+		}
+	}
+
+	return root, nil
+}
@@ -0,0 +1,39 @@
+package xmlparser_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate/adapters/parsers/xmlparser"
+)
+
+func TestParse(t *testing.T) {
+	body := []byte(`<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0">
+  <channel>
+    <item id="1"><title>caf` + "\xe9" + `</title></item>
+    <item id="2"><title>second</title></item>
+  </channel>
+</rss>`)
+
+	doc, err := xmlparser.New().Parse(context.Background(), body)
+	require.NoError(t, err)
+
+	root, ok := doc.(*xmlparser.Node)
+	require.True(t, ok)
+
+	rss := root.FindOne("rss")
+	require.NotNil(t, rss)
+	require.Equal(t, "2.0", rss.Attr("version"))
+
+	items := root.Find("item")
+	require.Len(t, items, 2)
+	require.Equal(t, "1", items[0].Attr("id"))
+	require.Equal(t, "café", items[0].FindOne("title").InnerText())
+	require.Equal(t, "second", items[1].InnerText())
+
+	_, err = xmlparser.New().Parse(context.Background(), []byte("<a><b></a>"))
+	require.Error(t, err)
+}
@@ -0,0 +1,302 @@
+// Package xpathparser parses HTML and XML documents into a Document that
+// can be queried with XPath 1.0 expressions.
+//
+// It plugs into scrapemate like any other parser:
+//
+//	scrapemate.WithHTMLParser(xpathparser.New())
+//
+// and jobs get the document from the response:
+//
+//	doc, ok := resp.Document.(*xpathparser.Document)
+//	title, err := doc.Text("//h1")
+//	links, err := doc.Texts("//a/@href")
+package xpathparser
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+// XPathParser parses response bodies into a *Document
+type XPathParser struct {
+	xml bool
+}
+
+// New returns a parser for HTML documents
+func New() *XPathParser {
+	return &XPathParser{}
+}
+
+// NewXML returns a parser for XML documents
+func NewXML() *XPathParser {
+	return &XPathParser{xml: true}
+}
+
+func (p *XPathParser) Parse(_ context.Context, body []byte) (any, error) {
+	var nav xpath.NodeNavigator
+
+	if p.xml {
+		root, err := xmlquery.Parse(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		nav = xmlquery.CreateXPathNavigator(root)
+	} else {
+		root, err := htmlquery.Parse(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		nav = htmlquery.CreateXPathNavigator(root)
+	}
+
+	return &Document{Node: Node{nav: nav}}, nil
+}
+
+// Document is a parsed document. All the Node helpers are evaluated
+// from the document root.
+type Document struct {
+	Node
+}
+
+// Node is a node of a Document. XPath expressions evaluated on a Node are
+// relative to it.
+type Node struct {
+	nav xpath.NodeNavigator
+}
+
+// Find returns the nodes matching expr
+func (n *Node) Find(expr string) ([]*Node, error) {
+	compiled, err := compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var ans []*Node
+
+	iter := compiled.Select(n.nav.Copy())
+	for iter.MoveNext() {
+		ans = append(ans, &Node{nav: iter.Current().Copy()})
+	}
+
+	return ans, nil
+}
+
+// FindOne returns the first node matching expr or nil if nothing matches
+func (n *Node) FindOne(expr string) (*Node, error) {
+	compiled, err := compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	iter := compiled.Select(n.nav.Copy())
+	if !iter.MoveNext() {
+		return nil, nil
+	}
+
+	return &Node{nav: iter.Current().Copy()}, nil
+}
+
+// Eval evaluates expr and returns its result. Depending on the expression
+// it's a float64, a string, a bool or a []*Node for node sets.
+func (n *Node) Eval(expr string) (any, error) {
+	compiled, err := compileFresh(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := compiled.Evaluate(n.nav.Copy()).(type) {
+	case *xpath.NodeIterator:
+		var nodes []*Node
+
+		for v.MoveNext() {
+			nodes = append(nodes, &Node{nav: v.Current().Copy()})
+		}
+
+		return nodes, nil
+	default:
+		return v, nil
+	}
+}
+
+// Text returns the trimmed string value of expr: the text of the first
+// matching node for node sets (the value for attributes) or the result of
+// string, number and boolean expressions such as "count(//a)".
+// It returns an empty string when nothing matches.
+func (n *Node) Text(expr string) (string, error) {
+	v, err := n.Eval(expr)
+	if err != nil {
+		return "", err
+	}
+
+	switch val := v.(type) {
+	case []*Node:
+		if len(val) == 0 {
+			return "", nil
+		}
+
+		return val[0].InnerText(), nil
+	case string:
+		return strings.TrimSpace(val), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	default:
+		return fmt.Sprint(val), nil
+	}
+}
+
+// Texts returns the trimmed text of every node matching expr
+func (n *Node) Texts(expr string) ([]string, error) {
+	nodes, err := n.Find(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		ans = append(ans, node.InnerText())
+	}
+
+	return ans, nil
+}
+
+// Attrs returns the value of attribute name of every node matching expr.
+// Nodes without the attribute are skipped.
+func (n *Node) Attrs(expr, name string) ([]string, error) {
+	nodes, err := n.Find(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := make([]string, 0, len(nodes))
+
+	for _, node := range nodes {
+		if v, ok := node.LookupAttr(name); ok {
+			ans = append(ans, v)
+		}
+	}
+
+	return ans, nil
+}
+
+// Name returns the local name of the node
+func (n *Node) Name() string {
+	return n.nav.LocalName()
+}
+
+// InnerText returns the trimmed text content of the node
+func (n *Node) InnerText() string {
+	return strings.TrimSpace(n.nav.Value())
+}
+
+// Attr returns the value of the attribute name or an empty string
+func (n *Node) Attr(name string) string {
+	v, _ := n.LookupAttr(name)
+
+	return v
+}
+
+// LookupAttr returns the value of the attribute name and whether it exists
+func (n *Node) LookupAttr(name string) (string, bool) {
+	nav := n.nav.Copy()
+
+	for nav.MoveToNextAttribute() {
+		if nav.LocalName() == name {
+			return nav.Value(), true
+		}
+	}
+
+	return "", false
+}
+
+// Unwrap returns the underlying node: a *html.Node for HTML documents
+// and a *xmlquery.Node for XML documents
+func (n *Node) Unwrap() any {
+	switch nav := n.nav.(type) {
+	case *htmlquery.NodeNavigator:
+		return nav.Current()
+	case *xmlquery.NodeNavigator:
+		return nav.Current()
+	default:
+		return nil
+	}
+}
+
+// OuterHTML returns the markup of the node including the node itself
+func (n *Node) OuterHTML() string {
+	switch node := n.Unwrap().(type) {
+	case *html.Node:
+		return htmlquery.OutputHTML(node, true)
+	case *xmlquery.Node:
+		return node.OutputXML(true)
+	default:
+		return ""
+	}
+}
+
+// maxCachedExprs bounds exprCache, expressions are usually constants
+// but callers may build them from scraped data
+const maxCachedExprs = 1024
+
+// exprCache holds compiled expressions for Select, which clones the query
+// of an expression before running it. Evaluate runs the query in place so
+// expressions given to it must not be shared.
+var exprCache = struct {
+	sync.Mutex
+	exprs map[string]*xpath.Expr
+}{exprs: make(map[string]*xpath.Expr)}
+
+// compile returns the compiled expr from the cache. It may only be used
+// with Select.
+func compile(expr string) (*xpath.Expr, error) {
+	exprCache.Lock()
+	compiled, ok := exprCache.exprs[expr]
+	exprCache.Unlock()
+
+	if ok {
+		return compiled, nil
+	}
+
+	compiled, err := compileFresh(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	exprCache.Lock()
+	defer exprCache.Unlock()
+
+	if len(exprCache.exprs) >= maxCachedExprs {
+		// drop an arbitrary entry
+		for k := range exprCache.exprs {
+			delete(exprCache.exprs, k)
+
+			break
+		}
+	}
+
+	exprCache.exprs[expr] = compiled
+
+	return compiled, nil
+}
+
+// compileFresh compiles expr without caching it
+func compileFresh(expr string) (*xpath.Expr, error) {
+	compiled, err := xpath.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpath %q: %w", expr, err)
+	}
+
+	return compiled, nil
+}
@@ -0,0 +1,119 @@
+package xpathparser_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate/adapters/parsers/xpathparser"
+)
+
+func TestParseHTML(t *testing.T) {
+	body := []byte(`<html><body>
+<h1> Books </h1>
+<ul>
+  <li class="book"><a href="/a">A</a><span class="price">10</span></li>
+  <li class="book"><a href="/b">B</a><span class="price">20</span></li>
+  <li class="book"><a>C</a></li>
+</ul>
+</body></html>`)
+
+	parsed, err := xpathparser.New().Parse(context.Background(), body)
+	require.NoError(t, err)
+
+	doc, ok := parsed.(*xpathparser.Document)
+	require.True(t, ok)
+
+	title, err := doc.Text("//h1")
+	require.NoError(t, err)
+	require.Equal(t, "Books", title)
+
+	count, err := doc.Text("count(//li[@class='book'])")
+	require.NoError(t, err)
+	require.Equal(t, "3", count)
+
+	hrefs, err := doc.Texts("//a/@href")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/a", "/b"}, hrefs)
+
+	attrs, err := doc.Attrs("//a", "href")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/a", "/b"}, attrs)
+
+	books, err := doc.Find("//li[@class='book']")
+	require.NoError(t, err)
+	require.Len(t, books, 3)
+
+	price, err := books[1].Text("./span[@class='price']")
+	require.NoError(t, err)
+	require.Equal(t, "20", price)
+	require.Equal(t, "li", books[0].Name())
+	require.Equal(t, "book", books[0].Attr("class"))
+
+	missing, err := doc.FindOne("//table")
+	require.NoError(t, err)
+	require.Nil(t, missing)
+
+	_, err = doc.Find("//li[")
+	require.Error(t, err)
+}
+
+func TestParseXML(t *testing.T) {
+	body := []byte(`<?xml version="1.0"?>
+<urlset>
+  <url><loc>https://example.com/1</loc><priority>0.5</priority></url>
+  <url><loc>https://example.com/2</loc></url>
+</urlset>`)
+
+	parsed, err := xpathparser.NewXML().Parse(context.Background(), body)
+	require.NoError(t, err)
+
+	doc, ok := parsed.(*xpathparser.Document)
+	require.True(t, ok)
+
+	locs, err := doc.Texts("/urlset/url/loc")
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://example.com/1", "https://example.com/2"}, locs)
+
+	node, err := doc.FindOne("//priority")
+	require.NoError(t, err)
+	require.Equal(t, "<priority>0.5</priority>", node.OuterHTML())
+}
+
+func TestConcurrentQueries(t *testing.T) {
+	parsed, err := xpathparser.New().Parse(context.Background(), []byte(`<html><body>
+<h1>Books</h1><a href="/a">A</a><a href="/b">B</a>
+</body></html>`))
+	require.NoError(t, err)
+
+	doc, ok := parsed.(*xpathparser.Document)
+	require.True(t, ok)
+
+	var wg sync.WaitGroup
+
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for range 100 {
+				title, err := doc.Text("//h1")
+				require.NoError(t, err)
+				require.Equal(t, "Books", title)
+
+				count, err := doc.Text("count(//a)")
+				require.NoError(t, err)
+				require.Equal(t, "2", count)
+
+				links, err := doc.Find("//a")
+				require.NoError(t, err)
+				require.Len(t, links, 2)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
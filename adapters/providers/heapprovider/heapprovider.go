@@ -0,0 +1,181 @@
+// Package heapprovider provides an in-memory JobProvider built on a
+// priority heap.
+//
+// Unlike the memory provider it accepts any integer priority, keeps the
+// jobs in a single heap instead of one goroutine per pushed job and can
+// bound the number of queued jobs. Delayed jobs (scrapemate.DelayedJob)
+// wait in a second heap until they are due.
+package heapprovider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/providers/internal/jobqueue"
+)
+
+var _ scrapemate.JobProvider = (*Provider)(nil)
+
+// ErrInvalidOption returned by New for negative option values
+var ErrInvalidOption = errors.New("invalid option")
+
+// Option configures a Provider
+type Option func(*Provider) error
+
+// WithMaxSize bounds the number of queued jobs. Push blocks while the
+// queue is full until a job is handed out or its context is done.
+// Zero means unbounded, the default.
+func WithMaxSize(n int) Option {
+	return func(p *Provider) error {
+		if n < 0 {
+			return ErrInvalidOption
+		}
+
+		p.maxSize = n
+
+		return nil
+	}
+}
+
+// WithAging raises the priority of waiting jobs by one level every
+// interval so low priority jobs are not starved by a steady stream of
+// higher priority ones. Zero disables aging, the default.
+func WithAging(interval time.Duration) Option {
+	return func(p *Provider) error {
+		if interval < 0 {
+			return ErrInvalidOption
+		}
+
+		p.aging = interval
+
+		return nil
+	}
+}
+
+// Provider is an in-memory JobProvider.
+// Jobs with a lower priority value are handed out first, like
+// PriorityHigh before PriorityLow, and jobs with the same priority in the
+// order they were pushed.
+type Provider struct {
+	maxSize int
+	aging   time.Duration
+	start   time.Time
+
+	mu      sync.Mutex
+	queue   jobqueue.Queue
+	delayed jobqueue.Delayed
+	seq     uint64
+	// wake is signaled when a job is pushed
+	wake jobqueue.Signal
+	// space is signaled when a job is handed out
+	space jobqueue.Signal
+}
+
+// New creates a Provider
+func New(options ...Option) (*Provider, error) {
+	p := Provider{
+		start: time.Now(),
+	}
+
+	for _, o := range options {
+		if err := o(&p); err != nil {
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+// Push queues the job. When the queue is full it waits for space and
+// returns the context error if ctx is done first.
+func (p *Provider) Push(ctx context.Context, job scrapemate.IJob) error {
+	for {
+		p.mu.Lock()
+
+		if p.maxSize == 0 || p.len() < p.maxSize {
+			p.seq++
+
+			it := &jobqueue.Item{Job: job, Seq: p.seq}
+
+			if due := scrapemate.NotBefore(job); time.Now().Before(due) {
+				it.Due = due
+				p.delayed.Push(it)
+				p.wake.Broadcast()
+			} else {
+				it.Rank = p.rank(job.GetPriority())
+				p.push(it)
+			}
+
+			p.mu.Unlock()
+
+			return nil
+		}
+
+		space := p.space.C()
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-space:
+		}
+	}
+}
+
+// Jobs returns the channel to get jobs from
+//
+//nolint:gocritic // we need to return a read only channel
+func (p *Provider) Jobs(ctx context.Context) (<-chan scrapemate.IJob, <-chan error) {
+	return jobqueue.Serve(ctx, &p.mu, p.next, p.push)
+}
+
+// Len returns the number of queued jobs, delayed ones included
+func (p *Provider) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.len()
+}
+
+func (p *Provider) len() int {
+	return p.queue.Len() + p.delayed.Len()
+}
+
+// next promotes the delayed jobs that are due and pops the first job.
+// It must be called with mu held.
+func (p *Provider) next(now time.Time) (*jobqueue.Item, <-chan struct{}, time.Time) {
+	due := p.delayed.Promote(now, func(it *jobqueue.Item) {
+		it.Rank = p.rank(it.Job.GetPriority())
+		p.push(it)
+	})
+
+	it := p.queue.Pop()
+	if it == nil {
+		return nil, p.wake.C(), due
+	}
+
+	p.space.Broadcast()
+
+	return it, nil, time.Time{}
+}
+
+// push adds it to the heap and wakes up the consumers.
+// It must be called with mu held.
+func (p *Provider) push(it *jobqueue.Item) {
+	p.queue.Push(it)
+	p.wake.Broadcast()
+}
+
+// rank orders the jobs. With aging a job pushed one interval later ranks
+// like a job of the next priority level, so waiting jobs overtake the
+// ones pushed after them with a higher priority.
+func (p *Provider) rank(priority int) int64 {
+	if p.aging == 0 {
+		return int64(priority)
+	}
+
+	return int64(priority)*int64(p.aging) + int64(time.Since(p.start))
+}
@@ -0,0 +1,141 @@
+package heapprovider_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/providers/heapprovider"
+	"github.com/gosom/scrapemate/adapters/providers/internal/providertest"
+)
+
+func TestProvider(t *testing.T) {
+	t.Run("notBefore", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := heapprovider.New()
+		require.NoError(t, err)
+
+		jobs, _ := p.Jobs(ctx)
+
+		start := time.Now()
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "later", NotBefore: start.Add(200 * time.Millisecond)}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "soon", NotBefore: start.Add(100 * time.Millisecond)}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "now", NotBefore: start.Add(-time.Second)}))
+		require.Equal(t, 3, p.Len())
+
+		require.Equal(t, "now", providertest.Receive(t, jobs).GetID())
+
+		require.Equal(t, "soon", providertest.Receive(t, jobs).GetID())
+		require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+		require.Equal(t, "later", providertest.Receive(t, jobs).GetID())
+		require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+		require.Zero(t, p.Len())
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := heapprovider.New()
+		require.NoError(t, err)
+
+		for _, job := range []*scrapemate.Job{
+			{ID: "low1", Priority: scrapemate.PriorityLow},
+			{ID: "high", Priority: scrapemate.PriorityHigh},
+			{ID: "custom", Priority: 10},
+			{ID: "low2", Priority: scrapemate.PriorityLow},
+			{ID: "urgent", Priority: -5},
+			{ID: "medium", Priority: scrapemate.PriorityMedium},
+		} {
+			require.NoError(t, p.Push(ctx, job))
+		}
+
+		require.Equal(t, 6, p.Len())
+
+		jobs, _ := p.Jobs(ctx)
+
+		var ids []string
+		for range 6 {
+			ids = append(ids, providertest.Receive(t, jobs).GetID())
+		}
+
+		require.Equal(t, []string{"urgent", "high", "medium", "low1", "low2", "custom"}, ids)
+		require.Zero(t, p.Len())
+	})
+
+	t.Run("waitsForPush", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := heapprovider.New()
+		require.NoError(t, err)
+
+		jobs, errc := p.Jobs(ctx)
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+		require.Equal(t, "1", providertest.Receive(t, jobs).GetID())
+
+		cancel()
+		require.ErrorIs(t, <-errc, context.Canceled)
+	})
+
+	t.Run("maxSize", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := heapprovider.New(heapprovider.WithMaxSize(2))
+		require.NoError(t, err)
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "2"}))
+
+		pushCtx, pushCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer pushCancel()
+
+		require.ErrorIs(t, p.Push(pushCtx, &scrapemate.Job{ID: "3"}), context.DeadlineExceeded)
+
+		pushed := make(chan error, 1)
+
+		go func() {
+			pushed <- p.Push(ctx, &scrapemate.Job{ID: "3"})
+		}()
+
+		jobs, _ := p.Jobs(ctx)
+
+		require.Equal(t, "1", providertest.Receive(t, jobs).GetID())
+		require.NoError(t, <-pushed)
+		require.Equal(t, "2", providertest.Receive(t, jobs).GetID())
+		require.Equal(t, "3", providertest.Receive(t, jobs).GetID())
+	})
+
+	t.Run("aging", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := heapprovider.New(heapprovider.WithAging(10 * time.Millisecond))
+		require.NoError(t, err)
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "low", Priority: scrapemate.PriorityLow}))
+
+		time.Sleep(50 * time.Millisecond)
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "high", Priority: scrapemate.PriorityHigh}))
+
+		jobs, _ := p.Jobs(ctx)
+
+		require.Equal(t, "low", providertest.Receive(t, jobs).GetID())
+		require.Equal(t, "high", providertest.Receive(t, jobs).GetID())
+	})
+
+	t.Run("invalidOption", func(t *testing.T) {
+		_, err := heapprovider.New(heapprovider.WithMaxSize(-1))
+		require.ErrorIs(t, err, heapprovider.ErrInvalidOption)
+	})
+}
@@ -0,0 +1,212 @@
+// Package hostfair provides an in-memory JobProvider that is fair across
+// hosts.
+//
+// Jobs are kept in a sub-queue per host and handed out round-robin
+// across the hosts, so a host with many queued jobs cannot starve the
+// others. Within a host jobs are handed out by priority, lower first, and
+// in push order. Weights let some hosts get more consecutive jobs per
+// turn. Delayed jobs (scrapemate.DelayedJob) join their host's queue once
+// they are due.
+package hostfair
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/providers/internal/jobqueue"
+)
+
+var _ scrapemate.JobProvider = (*Provider)(nil)
+
+// ErrInvalidWeight returned by New for weights lower than 1
+var ErrInvalidWeight = errors.New("invalid host weight")
+
+// Option configures a Provider
+type Option func(*Provider) error
+
+// WithHostWeight hands out up to weight consecutive jobs of host per
+// turn. Hosts have a weight of 1 by default.
+func WithHostWeight(host string, weight int) Option {
+	return func(p *Provider) error {
+		if weight < 1 {
+			return ErrInvalidWeight
+		}
+
+		p.weights[strings.ToLower(host)] = weight
+
+		return nil
+	}
+}
+
+// Provider is a host-fair in-memory JobProvider
+type Provider struct {
+	weights map[string]int
+
+	mu sync.Mutex
+	// hosts holds the hosts with queued jobs
+	hosts map[string]*hostQueue
+	// ring is the round-robin order of the hosts
+	ring    []*hostQueue
+	cursor  int
+	delayed jobqueue.Delayed
+	seq     uint64
+	count   int
+	// wake is signaled when a job is pushed
+	wake jobqueue.Signal
+}
+
+type hostQueue struct {
+	name   string
+	jobs   jobqueue.Queue
+	credit int
+}
+
+// New creates a Provider
+func New(options ...Option) (*Provider, error) {
+	p := Provider{
+		weights: make(map[string]int),
+		hosts:   make(map[string]*hostQueue),
+	}
+
+	for _, o := range options {
+		if err := o(&p); err != nil {
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+// Push queues the job in the queue of its host
+func (p *Provider) Push(_ context.Context, job scrapemate.IJob) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.seq++
+	p.count++
+
+	it := &jobqueue.Item{Job: job, Rank: int64(job.GetPriority()), Seq: p.seq, Group: Host(job)}
+
+	if due := scrapemate.NotBefore(job); time.Now().Before(due) {
+		it.Due = due
+		p.delayed.Push(it)
+		p.wake.Broadcast()
+
+		return nil
+	}
+
+	p.push(it)
+
+	return nil
+}
+
+// Jobs returns the channel to get jobs from
+//
+//nolint:gocritic // we need to return a read only channel
+func (p *Provider) Jobs(ctx context.Context) (<-chan scrapemate.IJob, <-chan error) {
+	return jobqueue.Serve(ctx, &p.mu, p.next, p.requeue)
+}
+
+// Len returns the number of queued jobs, delayed ones included
+func (p *Provider) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.count
+}
+
+// Hosts returns the number of hosts with jobs ready to be handed out
+func (p *Provider) Hosts() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.ring)
+}
+
+// Host returns the host that job is queued under: the lower cased host
+// name of its URL, without the port
+func Host(job scrapemate.IJob) string {
+	u, err := url.Parse(job.GetURL())
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+// next moves the delayed jobs that are due to their host queues and pops
+// the next job. It must be called with mu held.
+func (p *Provider) next(now time.Time) (*jobqueue.Item, <-chan struct{}, time.Time) {
+	due := p.delayed.Promote(now, p.push)
+
+	it := p.pop()
+	if it == nil {
+		return nil, p.wake.C(), due
+	}
+
+	return it, nil, time.Time{}
+}
+
+// requeue puts back a job that was not handed out.
+// It must be called with mu held.
+func (p *Provider) requeue(it *jobqueue.Item) {
+	p.count++
+	p.push(it)
+}
+
+// push adds it to the queue of its host and wakes up the consumers.
+// It must be called with mu held.
+func (p *Provider) push(it *jobqueue.Item) {
+	hq, ok := p.hosts[it.Group]
+	if !ok {
+		hq = &hostQueue{name: it.Group, credit: p.weight(it.Group)}
+		p.hosts[it.Group] = hq
+		p.ring = append(p.ring, hq)
+	}
+
+	hq.jobs.Push(it)
+	p.wake.Broadcast()
+}
+
+// pop takes the next job of the host whose turn it is, nil when there
+// are no jobs ready. It must be called with mu held.
+func (p *Provider) pop() *jobqueue.Item {
+	if len(p.ring) == 0 {
+		return nil
+	}
+
+	hq := p.ring[p.cursor]
+	it := hq.jobs.Pop()
+
+	p.count--
+	hq.credit--
+
+	switch {
+	case hq.jobs.Len() == 0:
+		// the cursor now points to the next host
+		delete(p.hosts, hq.name)
+		p.ring = append(p.ring[:p.cursor], p.ring[p.cursor+1:]...)
+	case hq.credit == 0:
+		hq.credit = p.weight(hq.name)
+		p.cursor++
+	}
+
+	if p.cursor >= len(p.ring) {
+		p.cursor = 0
+	}
+
+	return it
+}
+
+func (p *Provider) weight(host string) int {
+	if w, ok := p.weights[host]; ok {
+		return w
+	}
+
+	return 1
+}
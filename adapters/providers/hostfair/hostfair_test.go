@@ -0,0 +1,114 @@
+package hostfair_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/providers/hostfair"
+	"github.com/gosom/scrapemate/adapters/providers/internal/providertest"
+)
+
+func receiveHosts(t *testing.T, jobs <-chan scrapemate.IJob, n int) []string {
+	t.Helper()
+
+	hosts := make([]string, 0, n)
+	for range n {
+		hosts = append(hosts, hostfair.Host(providertest.Receive(t, jobs)))
+	}
+
+	return hosts
+}
+
+func TestProvider(t *testing.T) {
+	t.Run("roundRobin", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := hostfair.New()
+		require.NoError(t, err)
+
+		for i := range 100 {
+			require.NoError(t, p.Push(ctx, &scrapemate.Job{URL: fmt.Sprintf("https://big.example.com/%d", i)}))
+		}
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{URL: "https://a.example.com/"}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{URL: "https://b.example.com/1"}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{URL: "https://B.example.com:8080/2"}))
+		require.Equal(t, 103, p.Len())
+		require.Equal(t, 3, p.Hosts())
+
+		jobs, _ := p.Jobs(ctx)
+
+		require.Equal(t, []string{
+			"big.example.com", "a.example.com", "b.example.com",
+			"big.example.com", "b.example.com",
+			"big.example.com", "big.example.com",
+		}, receiveHosts(t, jobs, 7))
+		require.Equal(t, 1, p.Hosts())
+	})
+
+	t.Run("weights", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := hostfair.New(hostfair.WithHostWeight("big.example.com", 3))
+		require.NoError(t, err)
+
+		for i := range 10 {
+			require.NoError(t, p.Push(ctx, &scrapemate.Job{URL: fmt.Sprintf("https://big.example.com/%d", i)}))
+			require.NoError(t, p.Push(ctx, &scrapemate.Job{URL: fmt.Sprintf("https://small.example.com/%d", i)}))
+		}
+
+		jobs, _ := p.Jobs(ctx)
+
+		require.Equal(t, []string{
+			"big.example.com", "big.example.com", "big.example.com", "small.example.com",
+			"big.example.com", "big.example.com", "big.example.com", "small.example.com",
+		}, receiveHosts(t, jobs, 8))
+	})
+
+	t.Run("priorityWithinHost", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := hostfair.New()
+		require.NoError(t, err)
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "low", URL: "https://example.com/1", Priority: scrapemate.PriorityLow}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "high", URL: "https://example.com/2", Priority: scrapemate.PriorityHigh}))
+
+		jobs, _ := p.Jobs(ctx)
+
+		require.Equal(t, "high", providertest.Receive(t, jobs).GetID())
+		require.Equal(t, "low", providertest.Receive(t, jobs).GetID())
+	})
+
+	t.Run("notBefore", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := hostfair.New()
+		require.NoError(t, err)
+
+		jobs, _ := p.Jobs(ctx)
+
+		start := time.Now()
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "later", URL: "https://example.com/", NotBefore: start.Add(100 * time.Millisecond)}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "now", URL: "https://example.com/"}))
+
+		require.Equal(t, "now", providertest.Receive(t, jobs).GetID())
+		require.Equal(t, "later", providertest.Receive(t, jobs).GetID())
+		require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("invalidWeight", func(t *testing.T) {
+		_, err := hostfair.New(hostfair.WithHostWeight("example.com", 0))
+		require.ErrorIs(t, err, hostfair.ErrInvalidWeight)
+	})
+}
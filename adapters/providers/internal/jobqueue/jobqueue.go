@@ -0,0 +1,211 @@
+// Package jobqueue provides the in-memory queues shared by the heap and
+// host-fair providers.
+package jobqueue
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gosom/scrapemate"
+)
+
+// Item is a queued job
+type Item struct {
+	Job scrapemate.IJob
+	// Rank orders the ready items, lower first
+	Rank int64
+	// Seq is the push order, it orders items with the same rank
+	Seq uint64
+	// Due is when a delayed item is ready
+	Due time.Time
+	// Group is the sub-queue the item belongs to, like its host
+	Group string
+}
+
+// Queue hands out items by rank and then in push order.
+// The zero value is an empty queue.
+type Queue struct {
+	items byRank
+}
+
+// Push adds it to the queue
+func (q *Queue) Push(it *Item) {
+	heap.Push(&q.items, it)
+}
+
+// Pop removes and returns the first item, nil if the queue is empty
+func (q *Queue) Pop() *Item {
+	if q.items.Len() == 0 {
+		return nil
+	}
+
+	return heap.Pop(&q.items).(*Item) //nolint:errcheck // the queue only holds items
+}
+
+// Len returns the number of queued items
+func (q *Queue) Len() int {
+	return q.items.Len()
+}
+
+// Delayed holds items until they are due.
+// The zero value is an empty queue.
+type Delayed struct {
+	items byDue
+}
+
+// Push adds it to the queue
+func (d *Delayed) Push(it *Item) {
+	heap.Push(&d.items, it)
+}
+
+// Len returns the number of delayed items
+func (d *Delayed) Len() int {
+	return d.items.Len()
+}
+
+// Promote removes the items due at now passing them to ready, in due
+// order, and returns when the next item is due, the zero time if there is
+// none
+func (d *Delayed) Promote(now time.Time, ready func(*Item)) time.Time {
+	for d.items.Len() > 0 {
+		next := d.items.items[0]
+		if now.Before(next.Due) {
+			return next.Due
+		}
+
+		heap.Pop(&d.items)
+		ready(next)
+	}
+
+	return time.Time{}
+}
+
+// Signal wakes up the goroutines waiting for a change. Like the queues
+// it's guarded by its owner's mutex. The zero value is ready to use.
+type Signal struct {
+	c chan struct{}
+}
+
+// C returns a channel closed on the next Broadcast
+func (s *Signal) C() <-chan struct{} {
+	if s.c == nil {
+		s.c = make(chan struct{})
+	}
+
+	return s.c
+}
+
+// Broadcast wakes up the goroutines waiting on C
+func (s *Signal) Broadcast() {
+	if s.c != nil {
+		close(s.c)
+		s.c = nil
+	}
+}
+
+// Serve hands out jobs on the returned channel until ctx is done.
+// next is called with mu held and returns the next item, or a nil item, a
+// channel closed when one may be ready and when the next delayed item is
+// due, if any. An item that was taken when ctx is done is given back to
+// requeue, with mu held, to keep its place for the other consumers.
+//
+//nolint:gocritic // we need to return a read only channel
+func Serve(
+	ctx context.Context, mu *sync.Mutex, next func(now time.Time) (*Item, <-chan struct{}, time.Time), requeue func(*Item),
+) (<-chan scrapemate.IJob, <-chan error) {
+	out := make(chan scrapemate.IJob)
+	errc := make(chan error, 1)
+
+	go func() {
+		for {
+			mu.Lock()
+			it, wake, due := next(time.Now())
+			mu.Unlock()
+
+			if it == nil {
+				var timer <-chan time.Time
+				if !due.IsZero() {
+					timer = time.After(time.Until(due))
+				}
+
+				select {
+				case <-ctx.Done():
+					errc <- ctx.Err()
+
+					return
+				case <-wake:
+				case <-timer:
+				}
+
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				mu.Lock()
+				requeue(it)
+				mu.Unlock()
+
+				errc <- ctx.Err()
+
+				return
+			case out <- it.Job:
+			}
+		}
+	}()
+
+	return out, errc
+}
+
+// items implements the heap.Interface methods but Less
+type items []*Item
+
+func (q items) Len() int {
+	return len(q)
+}
+
+func (q items) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *items) Push(x any) {
+	*q = append(*q, x.(*Item)) //nolint:errcheck // the queue only holds items
+}
+
+func (q *items) Pop() any {
+	old := *q
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+
+	return it
+}
+
+// byRank implements heap.Interface ordering items by rank
+type byRank struct {
+	items
+}
+
+func (q byRank) Less(i, j int) bool {
+	if q.items[i].Rank != q.items[j].Rank {
+		return q.items[i].Rank < q.items[j].Rank
+	}
+
+	return q.items[i].Seq < q.items[j].Seq
+}
+
+// byDue implements heap.Interface ordering items by due time
+type byDue struct {
+	items
+}
+
+func (q byDue) Less(i, j int) bool {
+	if !q.items[i].Due.Equal(q.items[j].Due) {
+		return q.items[i].Due.Before(q.items[j].Due)
+	}
+
+	return q.items[i].Seq < q.items[j].Seq
+}
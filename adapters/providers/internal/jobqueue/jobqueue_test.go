@@ -0,0 +1,106 @@
+package jobqueue_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/providers/internal/jobqueue"
+	"github.com/gosom/scrapemate/adapters/providers/internal/providertest"
+)
+
+func item(id string, rank int64, seq uint64) *jobqueue.Item {
+	return &jobqueue.Item{Job: &scrapemate.Job{ID: id}, Rank: rank, Seq: seq}
+}
+
+func TestQueue(t *testing.T) {
+	var q jobqueue.Queue
+
+	require.Nil(t, q.Pop())
+
+	q.Push(item("c", 1, 3))
+	q.Push(item("b", 0, 2))
+	q.Push(item("a", 0, 1))
+	require.Equal(t, 3, q.Len())
+
+	var ids []string
+	for it := q.Pop(); it != nil; it = q.Pop() {
+		ids = append(ids, it.Job.GetID())
+	}
+
+	require.Equal(t, []string{"a", "b", "c"}, ids)
+}
+
+func TestDelayed(t *testing.T) {
+	var d jobqueue.Delayed
+
+	now := time.Now()
+
+	for i, due := range []time.Time{now.Add(time.Hour), now, now.Add(-time.Hour)} {
+		it := item(due.Format(time.RFC3339), 0, uint64(i))
+		it.Due = due
+		d.Push(it)
+	}
+
+	var ready []time.Time
+
+	next := d.Promote(now, func(it *jobqueue.Item) { ready = append(ready, it.Due) })
+	require.Equal(t, []time.Time{now.Add(-time.Hour), now}, ready)
+	require.Equal(t, now.Add(time.Hour), next)
+	require.Equal(t, 1, d.Len())
+}
+
+func TestServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var (
+		mu     sync.Mutex
+		q      jobqueue.Queue
+		wake   jobqueue.Signal
+		pushed = func(it *jobqueue.Item) {
+			q.Push(it)
+			wake.Broadcast()
+		}
+	)
+
+	next := func(time.Time) (*jobqueue.Item, <-chan struct{}, time.Time) {
+		if it := q.Pop(); it != nil {
+			return it, nil, time.Time{}
+		}
+
+		return nil, wake.C(), time.Time{}
+	}
+
+	jobs, errc := jobqueue.Serve(ctx, &mu, next, pushed)
+
+	mu.Lock()
+	pushed(item("1", 0, 1))
+	mu.Unlock()
+
+	require.Equal(t, "1", providertest.Receive(t, jobs).GetID())
+
+	mu.Lock()
+	pushed(item("2", 0, 2))
+	mu.Unlock()
+
+	// the second job is taken but nobody receives it
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return q.Len() == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, providertest.Receive(t, errc), context.Canceled)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Equal(t, 1, q.Len())
+	require.Equal(t, "2", q.Pop().Job.GetID())
+}
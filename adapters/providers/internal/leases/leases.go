@@ -0,0 +1,102 @@
+// Package leases tracks the leases of the jobs a provider handed out until
+// they are acknowledged.
+package leases
+
+import (
+	"iter"
+	"reflect"
+	"slices"
+
+	"github.com/gosom/scrapemate"
+)
+
+// Key returns the key of job in a Map. Jobs are told apart by identity,
+// except the ones that cannot be map keys, like value jobs with map
+// fields, which are told apart by ID.
+func Key(job scrapemate.IJob) any {
+	if reflect.TypeOf(job).Comparable() {
+		return job
+	}
+
+	return job.GetID()
+}
+
+// Map maps the jobs handed out to their leases. Jobs with the same Key
+// have a lease each, returned in the order they were added.
+// It is not safe for concurrent use.
+type Map[L any] struct {
+	leases map[any][]L
+}
+
+// New creates an empty Map
+func New[L any]() *Map[L] {
+	return &Map[L]{leases: make(map[any][]L)}
+}
+
+// Add adds lease l of job
+func (m *Map[L]) Add(job scrapemate.IJob, l L) {
+	key := Key(job)
+
+	m.leases[key] = append(m.leases[key], l)
+}
+
+// Get returns the first lease of job
+func (m *Map[L]) Get(job scrapemate.IJob) (L, bool) {
+	ls := m.leases[Key(job)]
+	if len(ls) == 0 {
+		var zero L
+
+		return zero, false
+	}
+
+	return ls[0], true
+}
+
+// Take removes and returns the first lease of job
+func (m *Map[L]) Take(job scrapemate.IJob) (L, bool) {
+	l, ok := m.Get(job)
+	if ok {
+		m.set(Key(job), m.leases[Key(job)][1:])
+	}
+
+	return l, ok
+}
+
+// All returns all the leases
+func (m *Map[L]) All() iter.Seq[L] {
+	return func(yield func(L) bool) {
+		for _, ls := range m.leases {
+			for _, l := range ls {
+				if !yield(l) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// DeleteFunc removes the leases for which del returns true
+func (m *Map[L]) DeleteFunc(del func(L) bool) {
+	for key, ls := range m.leases {
+		m.set(key, slices.DeleteFunc(ls, del))
+	}
+}
+
+// Len returns the number of leases
+func (m *Map[L]) Len() int {
+	var n int
+
+	for _, ls := range m.leases {
+		n += len(ls)
+	}
+
+	return n
+}
+
+func (m *Map[L]) set(key any, ls []L) {
+	if len(ls) == 0 {
+		delete(m.leases, key)
+	} else {
+		m.leases[key] = ls
+	}
+}
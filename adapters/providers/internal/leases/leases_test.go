@@ -0,0 +1,59 @@
+package leases_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/providers/internal/leases"
+	"github.com/gosom/scrapemate/adapters/providers/internal/providertest"
+)
+
+func TestMap(t *testing.T) {
+	t.Run("pointer jobs", func(t *testing.T) {
+		m := leases.New[string]()
+
+		a, b := &scrapemate.Job{ID: "1"}, &scrapemate.Job{ID: "1"}
+
+		m.Add(a, "a")
+		m.Add(b, "b")
+
+		l, ok := m.Take(b)
+		require.True(t, ok)
+		require.Equal(t, "b", l)
+
+		_, ok = m.Take(b)
+		require.False(t, ok)
+
+		l, ok = m.Get(a)
+		require.True(t, ok)
+		require.Equal(t, "a", l)
+	})
+
+	t.Run("value jobs", func(t *testing.T) {
+		m := leases.New[string]()
+
+		m.Add(providertest.ValueJob{Job: &scrapemate.Job{ID: "1"}}, "a")
+		m.Add(providertest.ValueJob{Job: &scrapemate.Job{ID: "1"}}, "b")
+		m.Add(providertest.ValueJob{Job: &scrapemate.Job{ID: "2"}}, "c")
+		require.Equal(t, 3, m.Len())
+
+		l, ok := m.Take(providertest.ValueJob{Job: &scrapemate.Job{ID: "1"}})
+		require.True(t, ok)
+		require.Equal(t, "a", l)
+
+		m.DeleteFunc(func(l string) bool { return l == "c" })
+		require.ElementsMatch(t, []string{"b"}, collect(m))
+	})
+}
+
+func collect(m *leases.Map[string]) []string {
+	var ans []string
+
+	for l := range m.All() {
+		ans = append(ans, l)
+	}
+
+	return ans
+}
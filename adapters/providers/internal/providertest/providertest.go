@@ -0,0 +1,61 @@
+// Package providertest provides the helpers shared by the tests of the
+// job providers.
+package providertest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+)
+
+// NewRegistry returns a registry encoding *scrapemate.Job values whole
+func NewRegistry(t testing.TB) *scrapemate.JobRegistry {
+	t.Helper()
+
+	registry := scrapemate.NewJobRegistry()
+	require.NoError(t, registry.RegisterType("job", &scrapemate.Job{}))
+
+	return registry
+}
+
+// ValueJob is a job that cannot be used as a map key
+type ValueJob struct {
+	*scrapemate.Job
+	Tags map[string]string
+}
+
+// ValueCodec decodes the *scrapemate.Job values of a registry as ValueJobs
+type ValueCodec struct {
+	*scrapemate.JobRegistry
+}
+
+// DecodeJob decodes data wrapping the job in a ValueJob
+func (c ValueCodec) DecodeJob(data []byte) (scrapemate.IJob, error) {
+	job, err := c.JobRegistry.DecodeJob(data)
+	if err != nil {
+		return nil, err
+	}
+
+	//nolint:errcheck // only *scrapemate.Job values are pushed
+	return ValueJob{Job: job.(*scrapemate.Job), Tags: map[string]string{}}, nil
+}
+
+// Receive returns the next value of c. It fails the test when nothing is
+// received within 5 seconds.
+func Receive[T any](t testing.TB, c <-chan T) T {
+	t.Helper()
+
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "nothing received")
+	}
+
+	var zero T
+
+	return zero
+}
@@ -0,0 +1,640 @@
+// Package leveldbprovider provides a durable JobProvider backed by LevelDB.
+//
+// Pushed jobs are stored in a priority queue on disk so they survive
+// crashes and restarts. The provider is a scrapemate.AckingProvider: jobs
+// handed out to workers are leased until the engine acknowledges them.
+// Leases not acknowledged within the visibility timeout are re-queued,
+// unless the job was handed out the maximum number of deliveries, and
+// leases left by a process that died are re-queued when the provider is
+// opened again. Delayed jobs (scrapemate.DelayedJob) are kept apart until
+// they are due.
+package leveldbprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/providers/internal/leases"
+)
+
+var _ scrapemate.AckingProvider = (*Provider)(nil)
+
+var (
+	queuePrefix   = []byte("q/")
+	leasePrefix   = []byte("l/")
+	delayedPrefix = []byte("d/")
+	seqKey        = []byte("m/seq")
+)
+
+// ErrUnknownJob returned when acknowledging a job that is not leased
+var ErrUnknownJob = errors.New("job is not leased")
+
+const (
+	pollInterval        = time.Second
+	defaultLeaseTimeout = 5 * time.Minute
+)
+
+// Option configures a Provider
+type Option func(*Provider)
+
+// WithVisibilityTimeout re-queues jobs that are not acknowledged within d
+// (default 5 minutes). With zero or negative d leases only expire when the
+// provider is opened again.
+func WithVisibilityTimeout(d time.Duration) Option {
+	return func(p *Provider) {
+		p.leaseTimeout = d
+	}
+}
+
+// WithMaxDeliveries sets how many times a job is handed out before Nack,
+// or the expiry of its lease, drops it instead of re-queuing it. The
+// default, 1, drops failed jobs right away since the engine already
+// retried them; zero or negative re-queues them forever. Leases abandoned
+// by a process that died are always re-queued.
+func WithMaxDeliveries(n int) Option {
+	return func(p *Provider) {
+		p.maxDeliveries = n
+	}
+}
+
+// WithSync makes every write wait for the data to reach the disk.
+// Without it jobs survive process crashes but may be lost on power loss.
+func WithSync() Option {
+	return func(p *Provider) {
+		p.writeOpts = &opt.WriteOptions{Sync: true}
+	}
+}
+
+// Provider is a JobProvider storing its queue in LevelDB.
+// Jobs with a lower priority value are handed out first, like the memory
+// provider does for PriorityHigh, PriorityMedium and PriorityLow, and jobs
+// with the same priority in the order they were pushed.
+type Provider struct {
+	db            *leveldb.DB
+	codec         scrapemate.JobCodec
+	leaseTimeout  time.Duration
+	maxDeliveries int
+	writeOpts     *opt.WriteOptions
+
+	// mu serializes queue changes
+	mu     sync.Mutex
+	seq    uint64
+	queued int
+	// leases maps the jobs handed out to the keys of their stored lease
+	leases *leases.Map[[]byte]
+	wake   chan struct{}
+}
+
+// lease is the stored value of a leased job
+type lease struct {
+	Deadline   time.Time `json:"deadline"`
+	QueueKey   []byte    `json:"queue_key"`
+	Deliveries int       `json:"deliveries"`
+	Data       []byte    `json:"data"`
+}
+
+// New opens the provider stored at path. Jobs are encoded with codec,
+// usually a *scrapemate.JobRegistry.
+func New(path string, codec scrapemate.JobCodec, options ...Option) (*Provider, error) {
+	if codec == nil {
+		return nil, errors.New("job codec is nil")
+	}
+
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	p := Provider{
+		db:            db,
+		codec:         codec,
+		leaseTimeout:  defaultLeaseTimeout,
+		maxDeliveries: 1,
+		leases:        leases.New[[]byte](),
+		wake:          make(chan struct{}),
+	}
+
+	for _, o := range options {
+		o(&p)
+	}
+
+	if err := p.recover(); err != nil {
+		_ = db.Close()
+
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// Push stores the job in the queue
+func (p *Provider) Push(_ context.Context, job scrapemate.IJob) error {
+	data, err := p.codec.EncodeJob(job)
+	if err != nil {
+		return fmt.Errorf("cannot encode job: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.seq++
+
+	qkey := queueKey(job.GetPriority(), p.seq)
+
+	batch := new(leveldb.Batch)
+
+	if due := scrapemate.NotBefore(job); time.Now().Before(due) {
+		// the queue key is kept to move it to the queue once due
+		batch.Put(delayedKey(due, p.seq), append(qkey, queueValue(0, data)...))
+	} else {
+		batch.Put(qkey, queueValue(0, data))
+	}
+
+	batch.Put(seqKey, binary.BigEndian.AppendUint64(nil, p.seq))
+
+	if err := p.db.Write(batch, p.writeOpts); err != nil {
+		return err
+	}
+
+	p.queued++
+	p.broadcast()
+
+	return nil
+}
+
+// Jobs returns the channel to get jobs from. Every job received is leased
+// until it's acknowledged with Ack or Nack.
+//
+//nolint:gocritic // we need to return a read only channel
+func (p *Provider) Jobs(ctx context.Context) (<-chan scrapemate.IJob, <-chan error) {
+	out := make(chan scrapemate.IJob)
+	errc := make(chan error, 1)
+
+	go func() {
+		// expired leases are checked every pollInterval, whether jobs
+		// are waiting or not
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := p.requeueExpired(); err != nil {
+					errc <- err
+
+					return
+				}
+			default:
+			}
+
+			job, wake, due, err := p.next(ctx)
+			if err != nil {
+				errc <- err
+
+				return
+			}
+
+			if job == nil {
+				var timer <-chan time.Time
+				if !due.IsZero() {
+					timer = time.After(time.Until(due))
+				}
+
+				select {
+				case <-timer:
+				case <-ctx.Done():
+					errc <- ctx.Err()
+
+					return
+				case <-wake:
+				case <-ticker.C:
+					if err := p.requeueExpired(); err != nil {
+						errc <- err
+
+						return
+					}
+				}
+
+				continue
+			}
+
+			if err := p.send(ctx, out, job, ticker.C); err != nil {
+				errc <- err
+
+				return
+			}
+		}
+	}()
+
+	return out, errc
+}
+
+// send sends job to out, re-queuing the expired leases on every tick
+// while it waits. When ctx is done the job is released and ctx's error
+// returned.
+func (p *Provider) send(ctx context.Context, out chan<- scrapemate.IJob, job scrapemate.IJob, tick <-chan time.Time) error {
+	for {
+		select {
+		case <-ctx.Done():
+			// nobody will process it
+			_ = p.release(job, false)
+
+			return ctx.Err()
+		case out <- job:
+			return nil
+		case <-tick:
+			if err := p.requeueExpired(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Ack removes the lease of a processed job
+func (p *Provider) Ack(_ context.Context, job scrapemate.IJob) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lkey, ok := p.leases.Get(job)
+	if !ok {
+		return ErrUnknownJob
+	}
+
+	if err := p.db.Delete(lkey, p.writeOpts); err != nil {
+		return err
+	}
+
+	p.leases.Take(job)
+
+	return nil
+}
+
+// Nack returns a failed job to the queue, or drops it when it was handed
+// out the number of times set with WithMaxDeliveries
+func (p *Provider) Nack(_ context.Context, job scrapemate.IJob, _ error) error {
+	return p.release(job, true)
+}
+
+// Len returns the number of queued jobs, delayed ones included and
+// leased ones excluded
+func (p *Provider) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.queued
+}
+
+// Close closes the database. Leased jobs are re-queued when it's opened again.
+func (p *Provider) Close() error {
+	return p.db.Close()
+}
+
+// next leases the first job of the queue. When the queue is empty it
+// returns a nil job, a channel closed on the next Push and when the next
+// delayed job is due, if any.
+func (p *Provider) next(ctx context.Context) (scrapemate.IJob, <-chan struct{}, time.Time, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	due, err := p.promote(time.Now())
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+
+	for {
+		iter := p.db.NewIterator(util.BytesPrefix(queuePrefix), nil)
+
+		if !iter.First() {
+			iter.Release()
+
+			return nil, p.wake, due, iter.Error()
+		}
+
+		qkey := append([]byte(nil), iter.Key()...)
+		deliveries, data := parseQueueValue(iter.Value())
+
+		iter.Release()
+
+		job, decodeErr := p.codec.DecodeJob(data)
+		if decodeErr != nil {
+			// a job that cannot be decoded would block the queue forever
+			scrapemate.GetLoggerFromContext(ctx).Error("dropping job that cannot be decoded", "error", decodeErr)
+
+			if err := p.db.Delete(qkey, p.writeOpts); err != nil {
+				return nil, nil, time.Time{}, err
+			}
+
+			p.queued--
+
+			continue
+		}
+
+		lkey := append(append([]byte(nil), leasePrefix...), qkey[len(queuePrefix):]...)
+
+		value, err := json.Marshal(lease{
+			Deadline:   p.deadline(),
+			QueueKey:   qkey,
+			Deliveries: deliveries + 1,
+			Data:       data,
+		})
+		if err != nil {
+			return nil, nil, time.Time{}, err
+		}
+
+		batch := new(leveldb.Batch)
+		batch.Delete(qkey)
+		batch.Put(lkey, value)
+
+		if err := p.db.Write(batch, p.writeOpts); err != nil {
+			return nil, nil, time.Time{}, err
+		}
+
+		p.queued--
+		p.leases.Add(job, lkey)
+
+		return job, nil, time.Time{}, nil
+	}
+}
+
+// promote moves the delayed jobs due at now to the queue and returns when
+// the next delayed job is due, the zero time if there is none.
+// It must be called with mu held.
+func (p *Provider) promote(now time.Time) (time.Time, error) {
+	iter := p.db.NewIterator(util.BytesPrefix(delayedPrefix), nil)
+	defer iter.Release()
+
+	var (
+		batch = new(leveldb.Batch)
+		next  time.Time
+	)
+
+	for iter.Next() {
+		due := parseDelayedKey(iter.Key())
+		if now.Before(due) {
+			next = due
+
+			break
+		}
+
+		batch.Delete(iter.Key())
+
+		// the value starts with the queue key
+		if value := iter.Value(); len(value) >= queueKeyLen {
+			batch.Put(value[:queueKeyLen], value[queueKeyLen:])
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return time.Time{}, err
+	}
+
+	if batch.Len() > 0 {
+		if err := p.db.Write(batch, p.writeOpts); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	return next, nil
+}
+
+func (p *Provider) deadline() time.Time {
+	if p.leaseTimeout <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(p.leaseTimeout)
+}
+
+// release ends the lease of job re-queuing it. Failed jobs delivered
+// maxDeliveries times are dropped. Jobs that were not delivered, like
+// the ones left when a Jobs context is done, do not count as delivered.
+func (p *Provider) release(job scrapemate.IJob, failed bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lkey, ok := p.leases.Get(job)
+	if !ok {
+		return ErrUnknownJob
+	}
+
+	l, err := p.getLease(lkey)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case failed && p.maxDeliveries > 0 && l.Deliveries >= p.maxDeliveries:
+		err = p.db.Delete(lkey, p.writeOpts)
+	case failed:
+		err = p.requeue(lkey, l)
+	default:
+		l.Deliveries--
+		err = p.requeue(lkey, l)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	p.leases.Take(job)
+
+	return nil
+}
+
+func (p *Provider) getLease(lkey []byte) (*lease, error) {
+	value, err := p.db.Get(lkey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var l lease
+
+	if err := json.Unmarshal(value, &l); err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// requeue moves the lease l stored at lkey back to the queue.
+// It must be called with mu held.
+func (p *Provider) requeue(lkey []byte, l *lease) error {
+	batch := new(leveldb.Batch)
+	batch.Delete(lkey)
+	batch.Put(l.QueueKey, queueValue(l.Deliveries, l.Data))
+
+	if err := p.db.Write(batch, p.writeOpts); err != nil {
+		return err
+	}
+
+	p.queued++
+	p.broadcast()
+
+	return nil
+}
+
+// requeueExpired re-queues the leases past their deadline, or drops them
+// when the job was delivered maxDeliveries times
+func (p *Provider) requeueExpired() error {
+	if p.leaseTimeout <= 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+
+	var expired [][]byte
+
+	defer p.leases.DeleteFunc(func(lkey []byte) bool {
+		return slices.ContainsFunc(expired, func(k []byte) bool { return bytes.Equal(k, lkey) })
+	})
+
+	for lkey := range p.leases.All() {
+		l, err := p.getLease(lkey)
+		if err != nil {
+			return err
+		}
+
+		if l.Deadline.IsZero() || now.Before(l.Deadline) {
+			continue
+		}
+
+		if p.maxDeliveries > 0 && l.Deliveries >= p.maxDeliveries {
+			err = p.db.Delete(lkey, p.writeOpts)
+		} else {
+			err = p.requeue(lkey, l)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		expired = append(expired, lkey)
+	}
+
+	return nil
+}
+
+// recover loads the sequence, re-queues the leases of a previous run and
+// counts the queued jobs
+func (p *Provider) recover() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	value, err := p.db.Get(seqKey, nil)
+
+	switch {
+	case errors.Is(err, leveldb.ErrNotFound):
+	case err != nil:
+		return err
+	default:
+		p.seq = binary.BigEndian.Uint64(value)
+	}
+
+	var stale [][]byte
+
+	iter := p.db.NewIterator(util.BytesPrefix(leasePrefix), nil)
+	for iter.Next() {
+		stale = append(stale, append([]byte(nil), iter.Key()...))
+	}
+
+	iter.Release()
+
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	for _, lkey := range stale {
+		l, err := p.getLease(lkey)
+		if err != nil {
+			return err
+		}
+
+		if err := p.requeue(lkey, l); err != nil {
+			return err
+		}
+	}
+
+	p.queued = 0
+
+	for _, prefix := range [][]byte{queuePrefix, delayedPrefix} {
+		iter = p.db.NewIterator(util.BytesPrefix(prefix), nil)
+		for iter.Next() {
+			p.queued++
+		}
+
+		iter.Release()
+
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// broadcast wakes up the goroutines waiting for jobs.
+// It must be called with mu held.
+func (p *Provider) broadcast() {
+	close(p.wake)
+	p.wake = make(chan struct{})
+}
+
+const (
+	signBit = 1 << 63
+	// queueKeyLen is the length of the keys returned by queueKey
+	queueKeyLen = 18
+)
+
+// queueKey sorts by priority and then by push order
+func queueKey(priority int, seq uint64) []byte {
+	key := append([]byte(nil), queuePrefix...)
+	key = binary.BigEndian.AppendUint64(key, uint64(int64(priority))^signBit)
+	key = binary.BigEndian.AppendUint64(key, seq)
+
+	return key
+}
+
+// queueValue prefixes the encoded job with the number of times it was
+// handed out
+func queueValue(deliveries int, data []byte) []byte {
+	return append(binary.AppendUvarint(nil, uint64(max(deliveries, 0))), data...)
+}
+
+func parseQueueValue(value []byte) (deliveries int, data []byte) {
+	n, size := binary.Uvarint(value)
+	if size <= 0 {
+		return 0, nil
+	}
+
+	return int(n), append([]byte(nil), value[size:]...) //nolint:gosec // delivery counts are small
+}
+
+// delayedKey sorts by due time and then by push order
+func delayedKey(due time.Time, seq uint64) []byte {
+	key := append([]byte(nil), delayedPrefix...)
+	key = binary.BigEndian.AppendUint64(key, uint64(due.UnixNano())^signBit)
+	key = binary.BigEndian.AppendUint64(key, seq)
+
+	return key
+}
+
+func parseDelayedKey(key []byte) time.Time {
+	nanos := binary.BigEndian.Uint64(key[len(delayedPrefix):]) ^ signBit
+
+	return time.Unix(0, int64(nanos)) //nolint:gosec // reverses delayedKey
+}
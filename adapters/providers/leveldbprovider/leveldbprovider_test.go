@@ -0,0 +1,302 @@
+package leveldbprovider_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/providers/internal/providertest"
+	"github.com/gosom/scrapemate/adapters/providers/leveldbprovider"
+)
+
+func TestProvider(t *testing.T) {
+	t.Run("notBefore", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := leveldbprovider.New(t.TempDir(), providertest.NewRegistry(t))
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = p.Close() })
+
+		jobs, _ := p.Jobs(ctx)
+
+		start := time.Now()
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "later", NotBefore: start.Add(200 * time.Millisecond)}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "soon", NotBefore: start.Add(100 * time.Millisecond)}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "now", NotBefore: start.Add(-time.Second)}))
+		require.Equal(t, 3, p.Len())
+
+		require.Equal(t, "now", providertest.Receive(t, jobs).GetID())
+
+		require.Equal(t, "soon", providertest.Receive(t, jobs).GetID())
+		require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+		require.Equal(t, "later", providertest.Receive(t, jobs).GetID())
+		require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+		require.Zero(t, p.Len())
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := leveldbprovider.New(t.TempDir(), providertest.NewRegistry(t))
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = p.Close() })
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "low1", Priority: scrapemate.PriorityLow}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "high", Priority: scrapemate.PriorityHigh}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "low2", Priority: scrapemate.PriorityLow}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "medium", Priority: scrapemate.PriorityMedium}))
+		require.Equal(t, 4, p.Len())
+
+		jobs, _ := p.Jobs(ctx)
+
+		var ids []string
+		for range 4 {
+			ids = append(ids, providertest.Receive(t, jobs).GetID())
+		}
+
+		require.Equal(t, []string{"high", "medium", "low1", "low2"}, ids)
+		require.Zero(t, p.Len())
+	})
+
+	t.Run("waitsForPush", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := leveldbprovider.New(t.TempDir(), providertest.NewRegistry(t))
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = p.Close() })
+
+		jobs, _ := p.Jobs(ctx)
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1", URL: "http://example.com"}))
+
+		job := providertest.Receive(t, jobs)
+		require.Equal(t, "1", job.GetID())
+		require.Equal(t, "http://example.com", job.GetURL())
+		require.NoError(t, p.Ack(ctx, job))
+		require.ErrorIs(t, p.Ack(ctx, job), leveldbprovider.ErrUnknownJob)
+	})
+
+	t.Run("persistsAcrossReopen", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		dir := t.TempDir()
+
+		p, err := leveldbprovider.New(dir, providertest.NewRegistry(t))
+		require.NoError(t, err)
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "2"}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "3"}))
+
+		jobs, _ := p.Jobs(ctx)
+
+		acked := providertest.Receive(t, jobs)
+		require.Equal(t, "1", acked.GetID())
+		require.NoError(t, p.Ack(ctx, acked))
+
+		// leased but never acknowledged, as if the process died
+		require.Equal(t, "2", providertest.Receive(t, jobs).GetID())
+
+		cancel()
+		require.NoError(t, p.Close())
+
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err = leveldbprovider.New(dir, providertest.NewRegistry(t))
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = p.Close() })
+
+		require.Equal(t, 2, p.Len())
+
+		jobs, _ = p.Jobs(ctx)
+
+		require.Equal(t, "2", providertest.Receive(t, jobs).GetID())
+		require.Equal(t, "3", providertest.Receive(t, jobs).GetID())
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "4"}))
+		require.Equal(t, "4", providertest.Receive(t, jobs).GetID())
+	})
+
+	t.Run("nack", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := leveldbprovider.New(t.TempDir(), providertest.NewRegistry(t), leveldbprovider.WithMaxDeliveries(2))
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = p.Close() })
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+
+		jobs, _ := p.Jobs(ctx)
+
+		job := providertest.Receive(t, jobs)
+		require.NoError(t, p.Nack(ctx, job, context.DeadlineExceeded))
+
+		job = providertest.Receive(t, jobs)
+		require.Equal(t, "1", job.GetID())
+		require.NoError(t, p.Nack(ctx, job, context.DeadlineExceeded))
+
+		// delivered twice, it's dropped
+		require.Zero(t, p.Len())
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "2"}))
+		require.Equal(t, "2", providertest.Receive(t, jobs).GetID())
+	})
+
+	t.Run("nackDropsByDefault", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := leveldbprovider.New(t.TempDir(), providertest.NewRegistry(t))
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = p.Close() })
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+
+		jobs, _ := p.Jobs(ctx)
+
+		require.NoError(t, p.Nack(ctx, providertest.Receive(t, jobs), context.DeadlineExceeded))
+		require.Zero(t, p.Len())
+	})
+
+	t.Run("visibilityTimeout", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := leveldbprovider.New(t.TempDir(), providertest.NewRegistry(t),
+			leveldbprovider.WithVisibilityTimeout(time.Millisecond), leveldbprovider.WithMaxDeliveries(2))
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = p.Close() })
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+
+		jobs, _ := p.Jobs(ctx)
+
+		first := providertest.Receive(t, jobs)
+		second := providertest.Receive(t, jobs)
+		require.Equal(t, first.GetID(), second.GetID())
+		require.ErrorIs(t, p.Ack(ctx, first), leveldbprovider.ErrUnknownJob)
+		require.NoError(t, p.Ack(ctx, second))
+	})
+
+	t.Run("visibilityTimeoutDropsByDefault", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := leveldbprovider.New(t.TempDir(), providertest.NewRegistry(t), leveldbprovider.WithVisibilityTimeout(time.Millisecond))
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = p.Close() })
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+
+		jobs, _ := p.Jobs(ctx)
+
+		first := providertest.Receive(t, jobs)
+
+		// leases are checked every second
+		select {
+		case job := <-jobs:
+			require.FailNow(t, "expired job re-queued", job.GetID())
+		case <-time.After(2 * time.Second):
+		}
+
+		require.ErrorIs(t, p.Ack(ctx, first), leveldbprovider.ErrUnknownJob)
+		require.Zero(t, p.Len())
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "2"}))
+		require.Equal(t, "2", providertest.Receive(t, jobs).GetID())
+	})
+
+	t.Run("visibilityTimeoutWithQueuedJobs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p, err := leveldbprovider.New(t.TempDir(), providertest.NewRegistry(t),
+			leveldbprovider.WithVisibilityTimeout(time.Millisecond), leveldbprovider.WithMaxDeliveries(0))
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = p.Close() })
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "2"}))
+
+		jobs, _ := p.Jobs(ctx)
+
+		require.Equal(t, "1", providertest.Receive(t, jobs).GetID())
+
+		// nobody receives the second job, the leases expire anyway
+		require.Eventually(t, func() bool { return p.Len() == 2 }, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("valueJobs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		registry := providertest.ValueCodec{JobRegistry: providertest.NewRegistry(t)}
+
+		p, err := leveldbprovider.New(t.TempDir(), registry)
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = p.Close() })
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "2"}))
+
+		jobs, _ := p.Jobs(ctx)
+
+		first, second, third := providertest.Receive(t, jobs), providertest.Receive(t, jobs), providertest.Receive(t, jobs)
+		require.Equal(t, []string{"1", "1", "2"}, []string{first.GetID(), second.GetID(), third.GetID()})
+
+		require.NoError(t, p.Ack(ctx, third))
+		require.NoError(t, p.Nack(ctx, first, context.DeadlineExceeded))
+		require.NoError(t, p.Ack(ctx, second))
+		require.ErrorIs(t, p.Ack(ctx, second), leveldbprovider.ErrUnknownJob)
+		require.Zero(t, p.Len())
+	})
+
+	t.Run("unknownType", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		dir := t.TempDir()
+
+		p, err := leveldbprovider.New(dir, scrapemate.NewJobRegistry())
+		require.NoError(t, err)
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+		require.NoError(t, p.Close())
+
+		p, err = leveldbprovider.New(dir, scrapemate.NewJobRegistry())
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = p.Close() })
+
+		jobs, _ := p.Jobs(ctx)
+
+		require.Eventually(t, func() bool { return p.Len() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+		select {
+		case job := <-jobs:
+			require.FailNow(t, "unexpected job", job.GetID())
+		default:
+		}
+	})
+}
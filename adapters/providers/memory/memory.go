@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"time"
 
 	"github.com/gosom/scrapemate"
 )
@@ -86,6 +87,18 @@ func (o *memoryProvider) Push(ctx context.Context, job scrapemate.IJob) error {
 			ch = o.p0
 		}
 
+		// hold delayed jobs until they are due
+		if wait := time.Until(scrapemate.NotBefore(job)); wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+
 		select {
 		case ch <- job:
 			return
@@ -0,0 +1,32 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var errLeaseNotFound = errors.New("lease not found")
+
+type pushRequest struct {
+	Job []byte `json:"job"`
+	// Parent is the lease of the job that returned Job, if any
+	Parent string `json:"parent,omitempty"`
+}
+
+type leaseResponse struct {
+	Lease string `json:"lease"`
+	Job   []byte `json:"job"`
+}
+
+type nackRequest struct {
+	Error string `json:"error"`
+}
+
+type resultRequest struct {
+	Job  []byte          `json:"job"`
+	Data json.RawMessage `json:"data"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
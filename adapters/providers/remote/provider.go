@@ -0,0 +1,297 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/providers/internal/leases"
+)
+
+var (
+	_ scrapemate.AckingProvider = (*Provider)(nil)
+	_ scrapemate.ResultWriter   = (*resultWriter)(nil)
+)
+
+var (
+	// ErrUnknownJob returned when acknowledging a job that is not leased
+	ErrUnknownJob = errors.New("job is not leased")
+	// ErrLeaseLost returned when acknowledging a job whose lease expired
+	// and that was handed out again
+	ErrLeaseLost = errors.New("job lease expired")
+	// ErrCoordinator returned when the coordinator rejects a request
+	ErrCoordinator = errors.New("coordinator error")
+)
+
+const releaseTimeout = 5 * time.Second
+
+// Option configures a Provider
+type Option func(*Provider)
+
+// WithHTTPClient sets the client used to talk to the coordinator,
+// http.DefaultClient by default. Leasing is long polling, so its Timeout
+// must be longer than the lease wait.
+func WithHTTPClient(client *http.Client) Option {
+	return func(p *Provider) {
+		p.client = client
+	}
+}
+
+// WithLeaseWait sets how long a lease request waits for a job before
+// asking again, 20 seconds by default. The coordinator caps it at a minute.
+func WithLeaseWait(d time.Duration) Option {
+	return func(p *Provider) {
+		p.leaseWait = d
+	}
+}
+
+// Provider is a JobProvider getting its jobs from a coordinator Server.
+// Jobs received are leased until they're acknowledged with Ack or Nack.
+type Provider struct {
+	baseURL   string
+	codec     scrapemate.JobCodec
+	client    *http.Client
+	leaseWait time.Duration
+
+	mu sync.Mutex
+	// leases maps the jobs handed out to their lease
+	leases *leases.Map[string]
+}
+
+// New creates a Provider for the coordinator at baseURL, like
+// http://coordinator:8080. codec must encode jobs like the coordinator's.
+func New(baseURL string, codec scrapemate.JobCodec, options ...Option) *Provider {
+	p := Provider{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		codec:     codec,
+		client:    http.DefaultClient,
+		leaseWait: defaultLeaseWait,
+		leases:    leases.New[string](),
+	}
+
+	for _, o := range options {
+		o(&p)
+	}
+
+	return &p
+}
+
+// Push sends job to the coordinator. Jobs pushed while processing a
+// leased job, like the next jobs the engine pushes, are sent along with
+// the lease of their parent.
+func (p *Provider) Push(ctx context.Context, job scrapemate.IJob) error {
+	data, err := p.codec.EncodeJob(job)
+	if err != nil {
+		return err
+	}
+
+	req := pushRequest{Job: data}
+
+	if parent := scrapemate.ParentJobFromContext(ctx); parent != nil {
+		p.mu.Lock()
+		req.Parent, _ = p.leases.Get(parent)
+		p.mu.Unlock()
+	}
+
+	_, err = p.post(ctx, "/v1/jobs", req, nil)
+
+	return err
+}
+
+// Jobs returns the channel to get jobs from. It long polls the coordinator
+// for them and fails when the coordinator cannot be reached.
+//
+//nolint:gocritic // we need to return a read only channel
+func (p *Provider) Jobs(ctx context.Context) (<-chan scrapemate.IJob, <-chan error) {
+	out := make(chan scrapemate.IJob)
+	errc := make(chan error, 1)
+
+	go func() {
+		for {
+			job, err := p.lease(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				}
+
+				errc <- err
+
+				return
+			}
+
+			if job == nil {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				// let another worker have it
+				p.release(job)
+
+				errc <- ctx.Err()
+
+				return
+			case out <- job:
+			}
+		}
+	}()
+
+	return out, errc
+}
+
+// Ack tells the coordinator that job was processed
+func (p *Provider) Ack(ctx context.Context, job scrapemate.IJob) error {
+	return p.finish(ctx, job, "ack", nil)
+}
+
+// Nack tells the coordinator that job failed
+func (p *Provider) Nack(ctx context.Context, job scrapemate.IJob, err error) error {
+	req := nackRequest{}
+	if err != nil {
+		req.Error = err.Error()
+	}
+
+	return p.finish(ctx, job, "nack", req)
+}
+
+// ResultWriter returns a ResultWriter reporting the results to the
+// coordinator, which passes them to its ResultHandler
+func (p *Provider) ResultWriter() scrapemate.ResultWriter {
+	return &resultWriter{p: p}
+}
+
+// lease asks the coordinator for a job, nil if none was ready in time or
+// the job received cannot be decoded
+func (p *Provider) lease(ctx context.Context) (scrapemate.IJob, error) {
+	var resp leaseResponse
+
+	status, err := p.post(ctx, "/v1/leases?wait="+url.QueryEscape(p.leaseWait.String()), nil, &resp)
+	if err != nil || status == http.StatusNoContent {
+		return nil, err
+	}
+
+	job, decodeErr := p.codec.DecodeJob(resp.Job)
+	if decodeErr != nil {
+		// handing it out again would fail the same way
+		scrapemate.GetLoggerFromContext(ctx).Error("failing job that cannot be decoded", "error", decodeErr)
+
+		_, err := p.post(ctx, "/v1/leases/"+resp.Lease+"/nack", nackRequest{Error: decodeErr.Error()}, nil)
+
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.leases.Add(job, resp.Lease)
+	p.mu.Unlock()
+
+	return job, nil
+}
+
+// release gives job back to the coordinator
+func (p *Provider) release(job scrapemate.IJob) {
+	id, ok := p.take(job)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+
+	_, _ = p.post(ctx, "/v1/leases/"+id+"/release", nil, nil)
+}
+
+func (p *Provider) finish(ctx context.Context, job scrapemate.IJob, action string, body any) error {
+	id, ok := p.take(job)
+	if !ok {
+		return ErrUnknownJob
+	}
+
+	status, err := p.post(ctx, "/v1/leases/"+id+"/"+action, body, nil)
+	if status == http.StatusNotFound {
+		return ErrLeaseLost
+	}
+
+	return err
+}
+
+// take removes the lease of job
+func (p *Provider) take(job scrapemate.IJob) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.leases.Take(job)
+}
+
+// post sends body as JSON to path and decodes the answer into out, when
+// there is one. It returns the status of the answer.
+func (p *Provider) post(ctx context.Context, path string, body, out any) (int, error) {
+	var buf bytes.Buffer
+
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return 0, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, &buf)
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var e errorResponse
+
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+
+		return resp.StatusCode, fmt.Errorf("%w: %s: %s", ErrCoordinator, resp.Status, e.Error)
+	}
+
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+
+	return resp.StatusCode, nil
+}
+
+type resultWriter struct {
+	p *Provider
+}
+
+func (w *resultWriter) Run(ctx context.Context, in <-chan scrapemate.Result) error {
+	for result := range in {
+		job, err := w.p.codec.EncodeJob(result.Job)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(result.Data)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.p.post(ctx, "/v1/results", resultRequest{Job: job, Data: data}, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
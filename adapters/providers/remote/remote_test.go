@@ -0,0 +1,397 @@
+package remote_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/fetchers/nethttp"
+	"github.com/gosom/scrapemate/adapters/providers/heapprovider"
+	"github.com/gosom/scrapemate/adapters/providers/internal/providertest"
+	"github.com/gosom/scrapemate/adapters/providers/remote"
+)
+
+const pages = 31
+
+type pageJob struct {
+	scrapemate.Job
+	Page int
+}
+
+// Process returns the page number and links to the pages 2n+1 and 2n+2
+func (j *pageJob) Process(_ context.Context, _ *scrapemate.Response) (any, []scrapemate.IJob, error) {
+	var next []scrapemate.IJob
+
+	for _, page := range []int{2*j.Page + 1, 2*j.Page + 2} {
+		if page < pages {
+			next = append(next, &pageJob{
+				Job:  scrapemate.Job{ID: strconv.Itoa(page), URL: fmt.Sprintf("%s/%d", baseURL(j.URL), page)},
+				Page: page,
+			})
+		}
+	}
+
+	return j.Page, next, nil
+}
+
+func baseURL(u string) string {
+	for i := len(u) - 1; i >= 0; i-- {
+		if u[i] == '/' {
+			return u[:i]
+		}
+	}
+
+	return u
+}
+
+func newRegistry(t *testing.T) *scrapemate.JobRegistry {
+	t.Helper()
+
+	registry := providertest.NewRegistry(t)
+	require.NoError(t, registry.RegisterType("page", &pageJob{}))
+
+	return registry
+}
+
+// ackingProvider records what the coordinator passes on to its provider
+type ackingProvider struct {
+	*heapprovider.Provider
+	acks    chan string
+	parents chan string
+}
+
+func newAckingProvider(t *testing.T) *ackingProvider {
+	t.Helper()
+
+	p, err := heapprovider.New()
+	require.NoError(t, err)
+
+	return &ackingProvider{Provider: p, acks: make(chan string, 10), parents: make(chan string, 10)}
+}
+
+func (p *ackingProvider) Push(ctx context.Context, job scrapemate.IJob) error {
+	if parent := scrapemate.ParentJobFromContext(ctx); parent != nil {
+		p.parents <- parent.GetID()
+	}
+
+	return p.Provider.Push(ctx, job)
+}
+
+func (p *ackingProvider) Ack(_ context.Context, job scrapemate.IJob) error {
+	p.acks <- "ack " + job.GetID()
+
+	return nil
+}
+
+func (p *ackingProvider) Nack(_ context.Context, job scrapemate.IJob, err error) error {
+	p.acks <- "nack " + job.GetID() + ": " + err.Error()
+
+	return nil
+}
+
+func startServer(ctx context.Context, t *testing.T, provider scrapemate.JobProvider, options ...remote.ServerOption) (*remote.Server, string) {
+	t.Helper()
+
+	srv, err := remote.NewServer(provider, newRegistry(t), options...)
+	require.NoError(t, err)
+
+	go func() {
+		_ = srv.Run(ctx)
+	}()
+
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	return srv, ts.URL
+}
+
+func newWorker(t *testing.T, url string) *remote.Provider {
+	t.Helper()
+
+	return remote.New(url, newRegistry(t), remote.WithLeaseWait(100*time.Millisecond))
+}
+
+func TestRemote(t *testing.T) {
+	t.Run("pushLeaseAck", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		inner := newAckingProvider(t)
+		srv, url := startServer(ctx, t, inner)
+		pusher, worker := newWorker(t, url), newWorker(t, url)
+
+		require.NoError(t, pusher.Push(ctx, &scrapemate.Job{ID: "1", URL: "http://example.com"}))
+
+		jobs, _ := worker.Jobs(ctx)
+
+		job := providertest.Receive(t, jobs)
+		require.Equal(t, "http://example.com", job.GetURL())
+		require.Equal(t, 1, srv.Leased())
+
+		require.NoError(t, worker.Ack(ctx, job))
+		require.Equal(t, "ack 1", providertest.Receive(t, inner.acks))
+		require.Zero(t, srv.Leased())
+		require.ErrorIs(t, worker.Ack(ctx, job), remote.ErrUnknownJob)
+	})
+
+	t.Run("nack", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		inner := newAckingProvider(t)
+		_, url := startServer(ctx, t, inner)
+		worker := newWorker(t, url)
+
+		require.NoError(t, worker.Push(ctx, &scrapemate.Job{ID: "1"}))
+
+		jobs, _ := worker.Jobs(ctx)
+
+		require.NoError(t, worker.Nack(ctx, providertest.Receive(t, jobs), errors.New("boom")))
+		require.Equal(t, "nack 1: boom", providertest.Receive(t, inner.acks))
+	})
+
+	t.Run("parent", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		inner := newAckingProvider(t)
+		_, url := startServer(ctx, t, inner)
+		worker := newWorker(t, url)
+
+		require.NoError(t, worker.Push(ctx, &scrapemate.Job{ID: "1"}))
+
+		jobs, _ := worker.Jobs(ctx)
+		parent := providertest.Receive(t, jobs)
+
+		require.NoError(t, worker.Push(scrapemate.ContextWithParentJob(ctx, parent), &scrapemate.Job{ID: "2"}))
+		require.Equal(t, "1", providertest.Receive(t, inner.parents))
+	})
+
+	t.Run("expiredLease", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		inner := newAckingProvider(t)
+		_, url := startServer(ctx, t, inner, remote.WithVisibilityTimeout(100*time.Millisecond))
+		crashed, worker := newWorker(t, url), newWorker(t, url)
+
+		require.NoError(t, worker.Push(ctx, &scrapemate.Job{ID: "1"}))
+
+		crashedJobs, _ := crashed.Jobs(ctx)
+		lost := providertest.Receive(t, crashedJobs)
+
+		jobs, _ := worker.Jobs(ctx)
+
+		job := providertest.Receive(t, jobs)
+		require.Equal(t, "1", job.GetID())
+		require.ErrorIs(t, crashed.Ack(ctx, lost), remote.ErrLeaseLost)
+		require.NoError(t, worker.Ack(ctx, job))
+		require.Equal(t, "ack 1", providertest.Receive(t, inner.acks))
+	})
+
+	t.Run("release", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		srv, url := startServer(ctx, t, newAckingProvider(t))
+		stopping, worker := newWorker(t, url), newWorker(t, url)
+
+		require.NoError(t, worker.Push(ctx, &scrapemate.Job{ID: "1"}))
+
+		// the job is leased but never taken from the channel
+		stoppingCtx, stop := context.WithCancel(ctx)
+		_, stoppingErrc := stopping.Jobs(stoppingCtx)
+
+		require.Eventually(t, func() bool { return srv.Leased() == 1 }, 5*time.Second, 10*time.Millisecond)
+		stop()
+		require.ErrorIs(t, providertest.Receive(t, stoppingErrc), context.Canceled)
+
+		jobs, _ := worker.Jobs(ctx)
+		require.Equal(t, "1", providertest.Receive(t, jobs).GetID())
+	})
+
+	t.Run("valueJobs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		inner := newAckingProvider(t)
+		_, url := startServer(ctx, t, inner)
+		worker := remote.New(url, providertest.ValueCodec{JobRegistry: newRegistry(t)}, remote.WithLeaseWait(100*time.Millisecond))
+
+		require.NoError(t, inner.Push(ctx, &scrapemate.Job{ID: "1"}))
+		require.NoError(t, inner.Push(ctx, &scrapemate.Job{ID: "1"}))
+
+		jobs, _ := worker.Jobs(ctx)
+		first, second := providertest.Receive(t, jobs), providertest.Receive(t, jobs)
+
+		require.NoError(t, worker.Push(scrapemate.ContextWithParentJob(ctx, first), &scrapemate.Job{ID: "2"}))
+		require.Equal(t, "1", providertest.Receive(t, inner.parents))
+
+		require.NoError(t, worker.Ack(ctx, first))
+		require.NoError(t, worker.Ack(ctx, second))
+		require.Equal(t, "ack 1", providertest.Receive(t, inner.acks))
+		require.Equal(t, "ack 1", providertest.Receive(t, inner.acks))
+		require.ErrorIs(t, worker.Ack(ctx, second), remote.ErrUnknownJob)
+	})
+
+	t.Run("undecodableJob", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		inner := newAckingProvider(t)
+		_, url := startServer(ctx, t, inner)
+
+		// the worker does not know the page jobs
+		registry := scrapemate.NewJobRegistry()
+		require.NoError(t, registry.RegisterType("job", &scrapemate.Job{}))
+
+		worker := remote.New(url, registry, remote.WithLeaseWait(100*time.Millisecond))
+
+		require.NoError(t, inner.Push(ctx, &pageJob{Job: scrapemate.Job{ID: "1"}}))
+		require.NoError(t, inner.Push(ctx, &scrapemate.Job{ID: "2"}))
+
+		jobs, _ := worker.Jobs(ctx)
+
+		require.Contains(t, providertest.Receive(t, inner.acks), "nack 1: ")
+		require.Equal(t, "2", providertest.Receive(t, jobs).GetID())
+	})
+
+	t.Run("invalidVisibilityTimeout", func(t *testing.T) {
+		for _, d := range []time.Duration{-time.Second, 0, time.Nanosecond} {
+			_, err := remote.NewServer(newAckingProvider(t), newRegistry(t), remote.WithVisibilityTimeout(d))
+			require.ErrorIs(t, err, remote.ErrInvalidOption)
+		}
+	})
+
+	t.Run("results", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		type result struct {
+			id   string
+			data string
+		}
+
+		results := make(chan result, 1)
+
+		_, url := startServer(ctx, t, newAckingProvider(t), remote.WithResultHandler(
+			func(_ context.Context, job scrapemate.IJob, data json.RawMessage) error {
+				results <- result{id: job.GetID(), data: string(data)}
+
+				return nil
+			}))
+
+		in := make(chan scrapemate.Result, 1)
+		in <- scrapemate.Result{Job: &scrapemate.Job{ID: "1"}, Data: map[string]int{"page": 1}}
+		close(in)
+
+		require.NoError(t, newWorker(t, url).ResultWriter().Run(ctx, in))
+		require.Equal(t, result{id: "1", data: `{"page":1}`}, providertest.Receive(t, results))
+	})
+
+	t.Run("resultsWithoutHandler", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		_, url := startServer(ctx, t, newAckingProvider(t))
+
+		in := make(chan scrapemate.Result, 1)
+		in <- scrapemate.Result{Job: &scrapemate.Job{ID: "1"}}
+		close(in)
+
+		require.ErrorIs(t, newWorker(t, url).ResultWriter().Run(ctx, in), remote.ErrCoordinator)
+	})
+}
+
+func TestRemote_severalWorkers(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(errors.New("defer exit"))
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("<html></html>"))
+	}))
+	defer site.Close()
+
+	inner, err := heapprovider.New()
+	require.NoError(t, err)
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[int]int)
+		done = make(chan struct{})
+	)
+
+	_, url := startServer(ctx, t, inner, remote.WithResultHandler(
+		func(_ context.Context, _ scrapemate.IJob, data json.RawMessage) error {
+			page, err := strconv.Atoi(string(data))
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			seen[page]++
+			if len(seen) == pages {
+				close(done)
+			}
+
+			return nil
+		}))
+
+	require.NoError(t, inner.Push(ctx, &pageJob{Job: scrapemate.Job{ID: "0", URL: site.URL + "/0"}}))
+
+	var wg sync.WaitGroup
+
+	for range 3 {
+		worker := newWorker(t, url)
+
+		mate, err := scrapemate.New(
+			scrapemate.WithContext(ctx, cancel),
+			scrapemate.WithConcurrency(2),
+			scrapemate.WithHTTPFetcher(nethttp.New(&http.Client{})),
+			scrapemate.WithJobProvider(worker),
+		)
+		require.NoError(t, err)
+
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			_ = mate.Start()
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			_ = worker.ResultWriter().Run(ctx, mate.Results())
+		}()
+	}
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "crawl not finished")
+	}
+
+	cancel(scrapemate.ErrorExitSignal)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for page := range pages {
+		require.Equal(t, 1, seen[page], "page %d", page)
+	}
+}
@@ -0,0 +1,497 @@
+// Package remote lets many scrapemate processes share the queue of one
+// coordinator over HTTP.
+//
+// The coordinator runs a Server wrapping any scrapemate.JobProvider. Worker
+// processes use a Provider pointing at it as their job provider: they
+// lease jobs from the coordinator, push the jobs they discover back to it
+// and acknowledge the jobs they finish. Results can be reported to the
+// coordinator too with the writer returned by Provider.ResultWriter.
+//
+// The protocol is plain HTTP with JSON bodies, jobs are encoded with a
+// scrapemate.JobCodec, like a scrapemate.JobRegistry, that both sides
+// share:
+//
+//	POST /v1/jobs                  push a job
+//	POST /v1/leases?wait=20s       lease a job, 204 when none is ready in time
+//	POST /v1/leases/{id}/ack       acknowledge a finished job
+//	POST /v1/leases/{id}/nack      report a failed job
+//	POST /v1/leases/{id}/release   give back a job that was not processed
+//	POST /v1/results               report a result
+//
+// The protocol has no authentication, run the coordinator in a trusted
+// network or behind a proxy that adds it.
+package remote
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gosom/scrapemate"
+)
+
+const (
+	// DefaultVisibilityTimeout is how long a worker has to acknowledge a
+	// leased job by default
+	DefaultVisibilityTimeout = 5 * time.Minute
+	// MinVisibilityTimeout is the shortest visibility timeout accepted
+	MinVisibilityTimeout = 100 * time.Millisecond
+
+	defaultLeaseWait = 20 * time.Second
+	maxLeaseWait     = time.Minute
+	expireInterval   = time.Second
+	maxRequestSize   = 32 << 20
+)
+
+// ErrInvalidOption returned by NewServer for invalid options
+var ErrInvalidOption = errors.New("invalid option")
+
+// ResultHandler receives the results reported by the workers.
+// Data is the JSON encoding of scrapemate.Result.Data.
+type ResultHandler func(ctx context.Context, job scrapemate.IJob, data json.RawMessage) error
+
+// ServerOption configures a Server
+type ServerOption func(*Server) error
+
+// WithVisibilityTimeout hands out again the jobs that are not acknowledged
+// within d, DefaultVisibilityTimeout by default. Set it above the time the
+// slowest job takes, retries included. It must be at least
+// MinVisibilityTimeout.
+func WithVisibilityTimeout(d time.Duration) ServerOption {
+	return func(s *Server) error {
+		if d < MinVisibilityTimeout {
+			return fmt.Errorf("%w: visibility timeout must be at least %s", ErrInvalidOption, MinVisibilityTimeout)
+		}
+
+		s.visibilityTimeout = d
+
+		return nil
+	}
+}
+
+// WithResultHandler accepts the results reported by the workers and
+// passes them to fn. Without it the coordinator rejects results.
+func WithResultHandler(fn ResultHandler) ServerOption {
+	return func(s *Server) error {
+		s.results = fn
+
+		return nil
+	}
+}
+
+// Server is the coordinator handing out the jobs of a JobProvider to
+// remote workers.
+//
+// Jobs leased by a worker are handed out again when they are not
+// acknowledged within the visibility timeout or the worker gives them
+// back. Acks and nacks are passed on to the wrapped provider when it's a
+// scrapemate.AckingProvider, so a durable provider keeps the jobs leased
+// to workers that are gone when the coordinator restarts.
+type Server struct {
+	provider          scrapemate.JobProvider
+	codec             scrapemate.JobCodec
+	visibilityTimeout time.Duration
+	results           ResultHandler
+	mux               *http.ServeMux
+
+	// ready hands the next job to a lease request
+	ready chan scrapemate.IJob
+
+	mu     sync.Mutex
+	leases map[string]*lease
+	// redeliver holds the jobs to hand out again before the provider's
+	redeliver []scrapemate.IJob
+	// wake is closed when a job is added to redeliver
+	wake chan struct{}
+}
+
+type lease struct {
+	job      scrapemate.IJob
+	deadline time.Time
+}
+
+// NewServer creates a Server handing out the jobs of provider.
+// Call Run to start handing them out.
+func NewServer(provider scrapemate.JobProvider, codec scrapemate.JobCodec, options ...ServerOption) (*Server, error) {
+	s := Server{
+		provider:          provider,
+		codec:             codec,
+		visibilityTimeout: DefaultVisibilityTimeout,
+		mux:               http.NewServeMux(),
+		ready:             make(chan scrapemate.IJob),
+		leases:            make(map[string]*lease),
+		wake:              make(chan struct{}),
+	}
+
+	for _, o := range options {
+		if err := o(&s); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mux.HandleFunc("POST /v1/jobs", s.handlePush)
+	s.mux.HandleFunc("POST /v1/leases", s.handleLease)
+	s.mux.HandleFunc("POST /v1/leases/{id}/ack", s.handleAck)
+	s.mux.HandleFunc("POST /v1/leases/{id}/nack", s.handleNack)
+	s.mux.HandleFunc("POST /v1/leases/{id}/release", s.handleRelease)
+
+	if s.results != nil {
+		s.mux.HandleFunc("POST /v1/results", s.handleResult)
+	}
+
+	return &s, nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Run takes jobs from the provider and hands them out to the lease
+// requests until ctx is done. Jobs leased when it returns are lost unless
+// the provider is an AckingProvider.
+func (s *Server) Run(ctx context.Context) error {
+	ticker := time.NewTicker(min(expireInterval, s.visibilityTimeout/2))
+	defer ticker.Stop()
+
+	jobc, errc := s.provider.Jobs(ctx)
+
+	for {
+		select {
+		case <-ticker.C:
+			s.expire(time.Now())
+		default:
+		}
+
+		job, wake := s.popRedelivery()
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case err := <-errc:
+				if ctx.Err() != nil {
+					return nil
+				}
+
+				scrapemate.GetLoggerFromContext(ctx).Error("error while getting jobs...going to wait a bit", "error", err)
+
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(time.Second):
+				}
+
+				jobc, errc = s.provider.Jobs(ctx)
+
+				continue
+			case <-wake:
+				continue
+			case <-ticker.C:
+				s.expire(time.Now())
+
+				continue
+			case job = <-jobc:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case s.ready <- job:
+		}
+	}
+}
+
+// Leased returns the number of jobs leased to workers
+func (s *Server) Leased() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.leases)
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	var req pushRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	job, err := s.codec.DecodeJob(req.Job)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	ctx := r.Context()
+
+	if req.Parent != "" {
+		if l := s.lease(req.Parent); l != nil {
+			ctx = scrapemate.ContextWithParentJob(ctx, l.job)
+		}
+	}
+
+	if err := s.provider.Push(ctx, job); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleLease(w http.ResponseWriter, r *http.Request) {
+	wait := defaultLeaseWait
+
+	if v := r.URL.Query().Get("wait"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			writeError(w, http.StatusBadRequest, errors.New("invalid wait"))
+
+			return
+		}
+
+		wait = min(d, maxLeaseWait)
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	var job scrapemate.IJob
+
+	select {
+	case <-r.Context().Done():
+		return
+	case <-timer.C:
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	case job = <-s.ready:
+	}
+
+	data, err := s.codec.EncodeJob(job)
+	if err != nil {
+		_ = s.finish(r.Context(), job, err)
+		writeError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	id := newLeaseID()
+
+	s.mu.Lock()
+	s.leases[id] = &lease{job: job, deadline: time.Now().Add(s.visibilityTimeout)}
+	s.mu.Unlock()
+
+	if err := writeJSON(w, http.StatusOK, leaseResponse{Lease: id, Job: data}); err != nil {
+		// the worker is gone
+		s.release(id)
+	}
+}
+
+func (s *Server) handleAck(w http.ResponseWriter, r *http.Request) {
+	job := s.take(r.PathValue("id"))
+	if job == nil {
+		writeError(w, http.StatusNotFound, errLeaseNotFound)
+
+		return
+	}
+
+	if err := s.finish(r.Context(), job, nil); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleNack(w http.ResponseWriter, r *http.Request) {
+	var req nackRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	job := s.take(r.PathValue("id"))
+	if job == nil {
+		writeError(w, http.StatusNotFound, errLeaseNotFound)
+
+		return
+	}
+
+	if err := s.finish(r.Context(), job, errors.New(req.Error)); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRelease(w http.ResponseWriter, r *http.Request) {
+	if !s.release(r.PathValue("id")) {
+		writeError(w, http.StatusNotFound, errLeaseNotFound)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleResult(w http.ResponseWriter, r *http.Request) {
+	var req resultRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	job, err := s.codec.DecodeJob(req.Job)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if err := s.results(r.Context(), job, req.Data); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finish acknowledges job to the provider, Ack when jobErr is nil and
+// Nack otherwise, when it's an AckingProvider
+func (s *Server) finish(ctx context.Context, job scrapemate.IJob, jobErr error) error {
+	acker, ok := s.provider.(scrapemate.AckingProvider)
+	if !ok {
+		return nil
+	}
+
+	if jobErr == nil {
+		return acker.Ack(ctx, job)
+	}
+
+	return acker.Nack(ctx, job, jobErr)
+}
+
+func (s *Server) lease(id string) *lease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.leases[id]
+}
+
+// take removes the lease id and returns its job, nil if there is none
+func (s *Server) take(id string) scrapemate.IJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.leases[id]
+	if !ok {
+		return nil
+	}
+
+	delete(s.leases, id)
+
+	return l.job
+}
+
+// release hands out the job of lease id again
+func (s *Server) release(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.leases[id]
+	if !ok {
+		return false
+	}
+
+	delete(s.leases, id)
+	s.redeliver = append(s.redeliver, l.job)
+	s.broadcast()
+
+	return true
+}
+
+// expire hands out again the jobs whose lease expired at now
+func (s *Server) expire(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := false
+
+	for id, l := range s.leases {
+		if now.Before(l.deadline) {
+			continue
+		}
+
+		delete(s.leases, id)
+		s.redeliver = append(s.redeliver, l.job)
+
+		expired = true
+	}
+
+	if expired {
+		s.broadcast()
+	}
+}
+
+// popRedelivery returns the next job to hand out again, or nil and a
+// channel closed when there is one
+func (s *Server) popRedelivery() (scrapemate.IJob, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.redeliver) == 0 {
+		return nil, s.wake
+	}
+
+	job := s.redeliver[0]
+	s.redeliver[0] = nil
+	s.redeliver = s.redeliver[1:]
+
+	return job, nil
+}
+
+// broadcast wakes up Run.
+// It must be called with mu held.
+func (s *Server) broadcast() {
+	close(s.wake)
+	s.wake = make(chan struct{})
+}
+
+func newLeaseID() string {
+	var b [16]byte
+
+	_, _ = rand.Read(b[:])
+
+	return hex.EncodeToString(b[:])
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	return json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	_ = writeJSON(w, status, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,504 @@
+// Package sqlprovider provides a JobProvider storing jobs in a relational
+// table through database/sql.
+//
+// Jobs are leased atomically: a leased row is marked with its lease expiry
+// and attempt count, and it is handed out again if it's not acknowledged
+// before the lease expires, unless it was handed out the maximum number of
+// attempts. Rows are handed out by priority, lower first,
+// then in push order, once their scheduled time (scrapemate.DelayedJob)
+// is reached.
+//
+// SQLite suits a single node; use a single connection
+// (db.SetMaxOpenConns(1)) or a busy timeout to avoid "database is
+// locked" errors. Postgres leases rows with FOR UPDATE SKIP LOCKED so many
+// nodes can share a table.
+package sqlprovider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/providers/internal/leases"
+)
+
+var _ scrapemate.AckingProvider = (*Provider)(nil)
+
+// Dialect is the SQL dialect of the database
+type Dialect int
+
+const (
+	// DialectSQLite is for SQLite 3.35 or newer
+	DialectSQLite Dialect = iota
+	// DialectPostgres is for PostgreSQL 9.5 or newer
+	DialectPostgres
+)
+
+// DefaultTable is the table used unless WithTable is set
+const DefaultTable = "scrapemate_jobs"
+
+// row statuses
+const (
+	statusQueued = "queued"
+	statusLeased = "leased"
+	statusFailed = "failed"
+)
+
+var (
+	// ErrUnknownJob returned when acknowledging a job that is not leased
+	ErrUnknownJob = errors.New("job is not leased")
+	// ErrLeaseLost returned when acknowledging a job whose lease expired
+	// and was taken by another consumer
+	ErrLeaseLost = errors.New("job lease lost")
+	// ErrInvalidOption returned by New for invalid options
+	ErrInvalidOption = errors.New("invalid option")
+)
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Option configures a Provider
+type Option func(*Provider) error
+
+// WithTable sets the table of the jobs, DefaultTable by default
+func WithTable(table string) Option {
+	return func(p *Provider) error {
+		if !identifier.MatchString(table) {
+			return fmt.Errorf("%w: table name %q", ErrInvalidOption, table)
+		}
+
+		p.table = table
+
+		return nil
+	}
+}
+
+// WithVisibilityTimeout sets how long a job is leased before it's handed
+// out again if not acknowledged (default 5 minutes)
+func WithVisibilityTimeout(d time.Duration) Option {
+	return func(p *Provider) error {
+		if d <= 0 {
+			return fmt.Errorf("%w: visibility timeout must be positive", ErrInvalidOption)
+		}
+
+		p.leaseTimeout = d
+
+		return nil
+	}
+}
+
+// WithMaxAttempts sets how many times a job is handed out before Nack, or
+// the expiry of its lease, marks it failed instead of re-queuing it. The
+// default, 1, fails jobs right away since the engine already retried them;
+// zero or negative re-queues them forever. Failed rows are kept with their
+// last error.
+func WithMaxAttempts(n int) Option {
+	return func(p *Provider) error {
+		p.maxAttempts = n
+
+		return nil
+	}
+}
+
+// WithPollInterval sets how often an idle consumer looks for jobs pushed
+// by other processes (default 1 second)
+func WithPollInterval(d time.Duration) Option {
+	return func(p *Provider) error {
+		if d <= 0 {
+			return fmt.Errorf("%w: poll interval must be positive", ErrInvalidOption)
+		}
+
+		p.pollInterval = d
+
+		return nil
+	}
+}
+
+// Provider is a JobProvider storing its jobs in a SQL table
+type Provider struct {
+	db           *sql.DB
+	dialect      Dialect
+	codec        scrapemate.JobCodec
+	table        string
+	leaseTimeout time.Duration
+	maxAttempts  int
+	pollInterval time.Duration
+
+	mu sync.Mutex
+	// leases maps the jobs handed out to their row
+	leases *leases.Map[lease]
+	// wake is closed when a job is pushed
+	wake chan struct{}
+}
+
+type lease struct {
+	id       int64
+	attempts int
+}
+
+// New creates a Provider storing jobs in db, creating its table if
+// needed. Jobs are encoded with codec, usually a *scrapemate.JobRegistry.
+func New(ctx context.Context, db *sql.DB, dialect Dialect, codec scrapemate.JobCodec, options ...Option) (*Provider, error) {
+	if db == nil || codec == nil {
+		return nil, fmt.Errorf("%w: db and codec are required", ErrInvalidOption)
+	}
+
+	if dialect != DialectSQLite && dialect != DialectPostgres {
+		return nil, fmt.Errorf("%w: unknown dialect %d", ErrInvalidOption, dialect)
+	}
+
+	const (
+		defaultLeaseTimeout = 5 * time.Minute
+		defaultPollInterval = time.Second
+	)
+
+	p := Provider{
+		db:           db,
+		dialect:      dialect,
+		codec:        codec,
+		table:        DefaultTable,
+		leaseTimeout: defaultLeaseTimeout,
+		maxAttempts:  1,
+		pollInterval: defaultPollInterval,
+		leases:       leases.New[lease](),
+		wake:         make(chan struct{}),
+	}
+
+	for _, o := range options {
+		if err := o(&p); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.createTable(ctx); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (p *Provider) createTable(ctx context.Context) error {
+	id, data := "INTEGER PRIMARY KEY AUTOINCREMENT", "BLOB"
+	if p.dialect == DialectPostgres {
+		id, data = "BIGSERIAL PRIMARY KEY", "BYTEA"
+	}
+
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS ` + p.table + ` (
+			id ` + id + `,
+			priority INTEGER NOT NULL,
+			run_at BIGINT NOT NULL,
+			status TEXT NOT NULL,
+			lease_expires_at BIGINT NOT NULL DEFAULT 0,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			data ` + data + ` NOT NULL,
+			created_at BIGINT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + p.table + `_next ON ` + p.table + ` (status, priority, id)`,
+	}
+
+	for _, q := range queries {
+		if _, err := p.db.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("cannot create table %s: %w", p.table, err)
+		}
+	}
+
+	return nil
+}
+
+// Push inserts the job in the table
+func (p *Provider) Push(ctx context.Context, job scrapemate.IJob) error {
+	data, err := p.codec.EncodeJob(job)
+	if err != nil {
+		return fmt.Errorf("cannot encode job: %w", err)
+	}
+
+	now := time.Now()
+
+	runAt := now
+	if due := scrapemate.NotBefore(job); due.After(now) {
+		runAt = due
+	}
+
+	_, err = p.db.ExecContext(ctx, p.rebind(`INSERT INTO `+p.table+
+		` (priority, run_at, status, data, created_at) VALUES (?, ?, ?, ?, ?)`),
+		job.GetPriority(), runAt.UnixMilli(), statusQueued, data, now.UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	close(p.wake)
+	p.wake = make(chan struct{})
+	p.mu.Unlock()
+
+	return nil
+}
+
+// Jobs returns the channel to get jobs from. Every job received is leased
+// until it's acknowledged with Ack or Nack or its lease expires.
+//
+//nolint:gocritic // we need to return a read only channel
+func (p *Provider) Jobs(ctx context.Context) (<-chan scrapemate.IJob, <-chan error) {
+	out := make(chan scrapemate.IJob)
+	errc := make(chan error, 1)
+
+	go func() {
+		ticker := time.NewTicker(p.pollInterval)
+		defer ticker.Stop()
+
+		for {
+			p.mu.Lock()
+			wake := p.wake
+			p.mu.Unlock()
+
+			job, err := p.next(ctx)
+			if err != nil {
+				errc <- err
+
+				return
+			}
+
+			if job == nil {
+				select {
+				case <-ctx.Done():
+					errc <- ctx.Err()
+
+					return
+				case <-wake:
+				case <-ticker.C:
+				}
+
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				// nobody will process it
+				_ = p.release(context.WithoutCancel(ctx), job)
+
+				errc <- ctx.Err()
+
+				return
+			case out <- job:
+			}
+		}
+	}()
+
+	return out, errc
+}
+
+// Ack deletes the row of a processed job
+func (p *Provider) Ack(ctx context.Context, job scrapemate.IJob) error {
+	l, err := p.takeLease(job)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.db.ExecContext(ctx, p.rebind(`DELETE FROM `+p.table+
+		` WHERE id = ? AND status = ? AND attempts = ?`), l.id, statusLeased, l.attempts)
+
+	return checkLease(res, err)
+}
+
+// Nack re-queues a failed job, or marks it failed when it was handed out
+// the number of times set with WithMaxAttempts
+func (p *Provider) Nack(ctx context.Context, job scrapemate.IJob, jobErr error) error {
+	l, err := p.takeLease(job)
+	if err != nil {
+		return err
+	}
+
+	status := statusQueued
+	if p.maxAttempts > 0 && l.attempts >= p.maxAttempts {
+		status = statusFailed
+	}
+
+	var msg string
+	if jobErr != nil {
+		msg = jobErr.Error()
+	}
+
+	res, err := p.db.ExecContext(ctx, p.rebind(`UPDATE `+p.table+
+		` SET status = ?, lease_expires_at = 0, last_error = ? WHERE id = ? AND status = ? AND attempts = ?`),
+		status, msg, l.id, statusLeased, l.attempts)
+
+	return checkLease(res, err)
+}
+
+// Len returns the number of jobs waiting to be handed out, scheduled ones
+// included. Unlike the Len of the in-memory and LevelDB providers it
+// counts the rows with a query, which takes a context and can fail, and
+// other processes sharing the table may change the count at any time.
+func (p *Provider) Len(ctx context.Context) (int, error) {
+	var n int
+
+	err := p.db.QueryRowContext(ctx, p.rebind(`SELECT COUNT(*) FROM `+p.table+` WHERE status = ?`),
+		statusQueued).Scan(&n)
+
+	return n, err
+}
+
+// next leases the next due job, nil when there is none
+func (p *Provider) next(ctx context.Context) (scrapemate.IJob, error) {
+	for {
+		now := time.Now()
+
+		args := []any{
+			statusLeased, now.Add(p.leaseTimeout).UnixMilli(),
+			statusQueued, now.UnixMilli(), statusLeased, now.UnixMilli(),
+		}
+
+		if p.maxAttempts > 0 {
+			if err := p.failExpired(ctx, now); err != nil {
+				return nil, err
+			}
+
+			args = append(args, p.maxAttempts)
+		}
+
+		var (
+			l    lease
+			data []byte
+		)
+
+		err := p.db.QueryRowContext(ctx, p.leaseQuery(), args...).Scan(&l.id, &l.attempts, &data)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		job, decodeErr := p.codec.DecodeJob(data)
+		if decodeErr != nil {
+			// a job that cannot be decoded would be handed out forever
+			scrapemate.GetLoggerFromContext(ctx).Error("failing job that cannot be decoded", "id", l.id, "error", decodeErr)
+
+			if _, err := p.db.ExecContext(ctx, p.rebind(`UPDATE `+p.table+
+				` SET status = ?, last_error = ? WHERE id = ?`), statusFailed, decodeErr.Error(), l.id); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		p.mu.Lock()
+		p.leases.Add(job, l)
+		p.mu.Unlock()
+
+		return job, nil
+	}
+}
+
+// leaseQuery returns the query leasing the next due job. Its arguments are
+// the leased status, the lease expiry, the queued status, the current
+// time, the leased status, the current time and, with a maximum number of
+// attempts, that maximum.
+func (p *Provider) leaseQuery() string {
+	var maxAttempts, skipLocked string
+
+	if p.maxAttempts > 0 {
+		maxAttempts = ` AND attempts < ?`
+	}
+
+	if p.dialect == DialectPostgres {
+		skipLocked = ` FOR UPDATE SKIP LOCKED`
+	}
+
+	return p.rebind(`UPDATE ` + p.table +
+		` SET status = ?, lease_expires_at = ?, attempts = attempts + 1` +
+		` WHERE id = (SELECT id FROM ` + p.table +
+		` WHERE (status = ? AND run_at <= ?) OR (status = ? AND lease_expires_at <= ?` + maxAttempts + `)` +
+		` ORDER BY priority, id LIMIT 1` + skipLocked + `)` +
+		` RETURNING id, attempts, data`)
+}
+
+// failExpired marks failed the jobs whose lease expired at now after they
+// were handed out the maximum number of attempts
+func (p *Provider) failExpired(ctx context.Context, now time.Time) error {
+	_, err := p.db.ExecContext(ctx, p.rebind(`UPDATE `+p.table+
+		` SET status = ?, lease_expires_at = 0, last_error = ?`+
+		` WHERE status = ? AND lease_expires_at <= ? AND attempts >= ?`),
+		statusFailed, "lease expired", statusLeased, now.UnixMilli(), p.maxAttempts)
+
+	return err
+}
+
+// release re-queues a job that was leased but not handed out
+func (p *Provider) release(ctx context.Context, job scrapemate.IJob) error {
+	l, err := p.takeLease(job)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.db.ExecContext(ctx, p.rebind(`UPDATE `+p.table+
+		` SET status = ?, lease_expires_at = 0, attempts = attempts - 1 WHERE id = ? AND status = ? AND attempts = ?`),
+		statusQueued, l.id, statusLeased, l.attempts)
+
+	return checkLease(res, err)
+}
+
+func (p *Provider) takeLease(job scrapemate.IJob) (lease, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l, ok := p.leases.Take(job)
+	if !ok {
+		return lease{}, ErrUnknownJob
+	}
+
+	return l, nil
+}
+
+// rebind replaces the ? placeholders of query with the ones of the dialect
+func (p *Provider) rebind(query string) string {
+	if p.dialect != DialectPostgres {
+		return query
+	}
+
+	var (
+		b strings.Builder
+		n int
+	)
+
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+
+			continue
+		}
+
+		n++
+
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+
+	return b.String()
+}
+
+func checkLease(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
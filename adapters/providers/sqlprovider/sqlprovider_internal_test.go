@@ -0,0 +1,39 @@
+package sqlprovider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider_rebind(t *testing.T) {
+	query := "UPDATE jobs SET status = ? WHERE id = ? AND attempts = ?"
+
+	sqlite := Provider{dialect: DialectSQLite}
+	require.Equal(t, query, sqlite.rebind(query))
+
+	postgres := Provider{dialect: DialectPostgres}
+	require.Equal(t, "UPDATE jobs SET status = $1 WHERE id = $2 AND attempts = $3", postgres.rebind(query))
+}
+
+func TestProvider_leaseQuery(t *testing.T) {
+	t.Run("postgres", func(t *testing.T) {
+		p := Provider{dialect: DialectPostgres, table: DefaultTable, maxAttempts: 1}
+
+		require.Equal(t, "UPDATE scrapemate_jobs SET status = $1, lease_expires_at = $2, attempts = attempts + 1"+
+			" WHERE id = (SELECT id FROM scrapemate_jobs"+
+			" WHERE (status = $3 AND run_at <= $4) OR (status = $5 AND lease_expires_at <= $6 AND attempts < $7)"+
+			" ORDER BY priority, id LIMIT 1 FOR UPDATE SKIP LOCKED)"+
+			" RETURNING id, attempts, data", p.leaseQuery())
+	})
+
+	t.Run("sqlite without max attempts", func(t *testing.T) {
+		p := Provider{dialect: DialectSQLite, table: "jobs"}
+
+		require.Equal(t, "UPDATE jobs SET status = ?, lease_expires_at = ?, attempts = attempts + 1"+
+			" WHERE id = (SELECT id FROM jobs"+
+			" WHERE (status = ? AND run_at <= ?) OR (status = ? AND lease_expires_at <= ?)"+
+			" ORDER BY priority, id LIMIT 1)"+
+			" RETURNING id, attempts, data", p.leaseQuery())
+	})
+}
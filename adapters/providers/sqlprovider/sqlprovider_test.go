@@ -0,0 +1,237 @@
+//go:build cgo
+
+// The tests run against SQLite through github.com/mattn/go-sqlite3, which
+// needs cgo.
+
+package sqlprovider_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/providers/internal/providertest"
+	"github.com/gosom/scrapemate/adapters/providers/sqlprovider"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "jobs.db")+"?_busy_timeout=5000")
+	require.NoError(t, err)
+
+	db.SetMaxOpenConns(1)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func newProvider(t *testing.T, db *sql.DB, options ...sqlprovider.Option) *sqlprovider.Provider {
+	t.Helper()
+
+	options = append([]sqlprovider.Option{sqlprovider.WithPollInterval(10 * time.Millisecond)}, options...)
+
+	p, err := sqlprovider.New(context.Background(), db, sqlprovider.DialectSQLite, providertest.NewRegistry(t), options...)
+	require.NoError(t, err)
+
+	return p
+}
+
+func queued(t *testing.T, p *sqlprovider.Provider) int {
+	t.Helper()
+
+	n, err := p.Len(context.Background())
+	require.NoError(t, err)
+
+	return n
+}
+
+func TestProvider(t *testing.T) {
+	t.Run("priority", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p := newProvider(t, openDB(t))
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "low1", Priority: scrapemate.PriorityLow}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "high", Priority: scrapemate.PriorityHigh}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "low2", Priority: scrapemate.PriorityLow}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "medium", Priority: scrapemate.PriorityMedium}))
+		require.Equal(t, 4, queued(t, p))
+
+		jobs, _ := p.Jobs(ctx)
+
+		var ids []string
+
+		for range 4 {
+			job := providertest.Receive(t, jobs)
+			require.NoError(t, p.Ack(ctx, job))
+
+			ids = append(ids, job.GetID())
+		}
+
+		require.Equal(t, []string{"high", "medium", "low1", "low2"}, ids)
+		require.Zero(t, queued(t, p))
+		require.ErrorIs(t, p.Ack(ctx, &scrapemate.Job{}), sqlprovider.ErrUnknownJob)
+	})
+
+	t.Run("notBefore", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		p := newProvider(t, openDB(t))
+
+		start := time.Now()
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "later", NotBefore: start.Add(200 * time.Millisecond)}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "now", Priority: scrapemate.PriorityLow}))
+
+		jobs, _ := p.Jobs(ctx)
+
+		require.Equal(t, "now", providertest.Receive(t, jobs).GetID())
+		require.Equal(t, "later", providertest.Receive(t, jobs).GetID())
+		require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	})
+
+	t.Run("nack", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		db := openDB(t)
+		p := newProvider(t, db, sqlprovider.WithMaxAttempts(2))
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+
+		jobs, _ := p.Jobs(ctx)
+
+		require.NoError(t, p.Nack(ctx, providertest.Receive(t, jobs), errors.New("first")))
+		require.NoError(t, p.Nack(ctx, providertest.Receive(t, jobs), errors.New("second")))
+		require.Zero(t, queued(t, p))
+
+		var (
+			status, lastError string
+			attempts          int
+		)
+
+		err := db.QueryRowContext(ctx, "SELECT status, attempts, last_error FROM "+sqlprovider.DefaultTable).
+			Scan(&status, &attempts, &lastError)
+		require.NoError(t, err)
+		require.Equal(t, "failed", status)
+		require.Equal(t, 2, attempts)
+		require.Equal(t, "second", lastError)
+	})
+
+	t.Run("expiredLease", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		db := openDB(t)
+		p := newProvider(t, db, sqlprovider.WithVisibilityTimeout(50*time.Millisecond), sqlprovider.WithMaxAttempts(2))
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+
+		// another process leases the job and dies
+		crashedCtx, crash := context.WithCancel(ctx)
+		crashed := newProvider(t, db, sqlprovider.WithVisibilityTimeout(50*time.Millisecond), sqlprovider.WithMaxAttempts(2))
+		crashedJobs, _ := crashed.Jobs(crashedCtx)
+		lost := providertest.Receive(t, crashedJobs)
+
+		crash()
+
+		jobs, _ := p.Jobs(ctx)
+
+		job := providertest.Receive(t, jobs)
+		require.Equal(t, "1", job.GetID())
+		require.ErrorIs(t, crashed.Ack(ctx, lost), sqlprovider.ErrLeaseLost)
+		require.NoError(t, p.Ack(ctx, job))
+	})
+
+	t.Run("expiredLeaseFails", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		db := openDB(t)
+		p := newProvider(t, db, sqlprovider.WithVisibilityTimeout(50*time.Millisecond))
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+
+		// the job is handed out once and its lease expires
+		crashedCtx, crash := context.WithCancel(ctx)
+		crashedJobs, _ := p.Jobs(crashedCtx)
+		lost := providertest.Receive(t, crashedJobs)
+
+		crash()
+
+		jobs, _ := p.Jobs(ctx)
+
+		require.Eventually(t, func() bool {
+			var status string
+
+			err := db.QueryRowContext(ctx, "SELECT status FROM "+sqlprovider.DefaultTable).Scan(&status)
+
+			return err == nil && status == "failed"
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.ErrorIs(t, p.Ack(ctx, lost), sqlprovider.ErrLeaseLost)
+
+		select {
+		case job := <-jobs:
+			require.FailNow(t, "unexpected job", job.GetID())
+		default:
+		}
+	})
+
+	t.Run("valueJobs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		registry := providertest.ValueCodec{JobRegistry: providertest.NewRegistry(t)}
+
+		p, err := sqlprovider.New(ctx, openDB(t), sqlprovider.DialectSQLite, registry,
+			sqlprovider.WithPollInterval(10*time.Millisecond), sqlprovider.WithMaxAttempts(2))
+		require.NoError(t, err)
+
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+		require.NoError(t, p.Push(ctx, &scrapemate.Job{ID: "1"}))
+
+		jobs, _ := p.Jobs(ctx)
+
+		first, second := providertest.Receive(t, jobs), providertest.Receive(t, jobs)
+
+		require.NoError(t, p.Nack(ctx, first, errors.New("boom")))
+		require.NoError(t, p.Ack(ctx, second))
+		require.NoError(t, p.Ack(ctx, providertest.Receive(t, jobs)))
+		require.ErrorIs(t, p.Ack(ctx, second), sqlprovider.ErrUnknownJob)
+		require.Zero(t, queued(t, p))
+	})
+
+	t.Run("persists", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		db := openDB(t)
+
+		require.NoError(t, newProvider(t, db).Push(ctx, &scrapemate.Job{ID: "1", URL: "http://example.com"}))
+
+		p := newProvider(t, db)
+		jobs, _ := p.Jobs(ctx)
+
+		job := providertest.Receive(t, jobs)
+		require.Equal(t, "http://example.com", job.GetURL())
+		require.NoError(t, p.Ack(ctx, job))
+	})
+
+	t.Run("invalidTable", func(t *testing.T) {
+		_, err := sqlprovider.New(context.Background(), openDB(t), sqlprovider.DialectSQLite, providertest.NewRegistry(t),
+			sqlprovider.WithTable("jobs; DROP TABLE users"))
+		require.ErrorIs(t, err, sqlprovider.ErrInvalidOption)
+	})
+}
@@ -0,0 +1,214 @@
+package warc
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/md5" //nolint:gosec // matches scrapemate.Job cache keys
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gosom/scrapemate"
+)
+
+var _ scrapemate.Cacher = (*Cache)(nil)
+
+// ErrReadOnly returned when writing to a Cache
+var ErrReadOnly = errors.New("warc cache is read only")
+
+// Cache serves the responses of .warc.gz archives as a read-only
+// scrapemate.Cacher, e.g. to replay a crawl.
+// Responses are looked up by the cache key recorded by Writer. Records of
+// other tools are indexed with the key of a GET scrapemate.Job for their
+// target URI. When a key was archived more than once the last one wins.
+type Cache struct {
+	index map[string]location
+}
+
+type location struct {
+	file   string
+	offset int64
+}
+
+// OpenCache indexes the .warc.gz files in paths, which can be files or
+// directories
+func OpenCache(paths ...string) (*Cache, error) {
+	var files []string
+
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, p)
+
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(p, "*.warc.gz"))
+		if err != nil {
+			return nil, err
+		}
+
+		// file names start with a timestamp so newer files are indexed last
+		sort.Strings(matches)
+
+		files = append(files, matches...)
+	}
+
+	c := Cache{index: make(map[string]location)}
+
+	for _, file := range files {
+		if err := c.indexFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	return &c, nil
+}
+
+// Len returns the number of indexed responses
+func (c *Cache) Len() int {
+	return len(c.index)
+}
+
+// Get returns the archived response for key
+func (c *Cache) Get(_ context.Context, key string) (scrapemate.Response, error) {
+	loc, ok := c.index[key]
+	if !ok {
+		return scrapemate.Response{}, fmt.Errorf("%w: %s", scrapemate.ErrCacheMiss, key)
+	}
+
+	f, err := os.Open(loc.file)
+	if err != nil {
+		return scrapemate.Response{}, err
+	}
+
+	defer f.Close()
+
+	if _, err := f.Seek(loc.offset, io.SeekStart); err != nil {
+		return scrapemate.Response{}, err
+	}
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return scrapemate.Response{}, err
+	}
+
+	zr.Multistream(false)
+
+	r := bufio.NewReader(zr)
+
+	for {
+		rec, err := readRecord(r)
+		if err != nil {
+			return scrapemate.Response{}, err
+		}
+
+		if rec.Type() == TypeResponse && recordKey(rec) == key {
+			return ParseResponse(rec)
+		}
+	}
+}
+
+// Set returns ErrReadOnly
+func (c *Cache) Set(context.Context, string, *scrapemate.Response) error {
+	return ErrReadOnly
+}
+
+// Close releases the index
+func (c *Cache) Close() error {
+	c.index = nil
+
+	return nil
+}
+
+// indexFile records the offset of the gzip member of every response
+func (c *Cache) indexFile(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	// gzip reads exactly the bytes of each member from an io.ByteReader
+	// so the counter holds the offset of the next member
+	cr := &countingReader{r: bufio.NewReader(f)}
+
+	zr, err := gzip.NewReader(cr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+
+	var offset int64
+
+	for {
+		zr.Multistream(false)
+
+		r := bufio.NewReader(zr)
+
+		for {
+			rec, err := readRecord(r)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+
+			if rec.Type() == TypeResponse {
+				c.index[recordKey(rec)] = location{file: file, offset: offset}
+			}
+		}
+
+		offset = cr.n
+
+		if err := zr.Reset(cr); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+}
+
+// recordKey returns the scrapemate cache key of a response record
+func recordKey(rec *Record) string {
+	if key := rec.Header.Get(HeaderCacheKey); key != "" {
+		return key
+	}
+
+	sum := md5.Sum([]byte("GET:" + strings.TrimSpace(rec.TargetURI()))) //nolint:gosec // matches scrapemate.Job cache keys
+
+	return hex.EncodeToString(sum[:])
+}
+
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+
+	return b, err
+}
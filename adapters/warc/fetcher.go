@@ -0,0 +1,43 @@
+package warc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gosom/scrapemate"
+)
+
+var _ scrapemate.HTTPFetcher = (*Fetcher)(nil)
+
+// Fetcher is an HTTPFetcher middleware that archives every successful
+// response of the wrapped fetcher with a Writer
+type Fetcher struct {
+	inner  scrapemate.HTTPFetcher
+	writer *Writer
+}
+
+// NewFetcher wraps inner. Closing the Fetcher closes inner and w.
+func NewFetcher(inner scrapemate.HTTPFetcher, w *Writer) *Fetcher {
+	return &Fetcher{inner: inner, writer: w}
+}
+
+// Fetch fetches the job with the wrapped fetcher and archives the response.
+// Archiving errors are logged, they don't fail the job.
+func (f *Fetcher) Fetch(ctx context.Context, job scrapemate.IJob) scrapemate.Response {
+	resp := f.inner.Fetch(ctx, job)
+
+	if resp.Error != nil || resp.StatusCode == 0 {
+		return resp
+	}
+
+	if err := f.writer.Write(job, &resp); err != nil {
+		scrapemate.GetLoggerFromContext(ctx).Error("cannot archive response", "error", err, "url", job.GetFullURL())
+	}
+
+	return resp
+}
+
+// Close closes the wrapped fetcher and the writer
+func (f *Fetcher) Close() error {
+	return errors.Join(f.inner.Close(), f.writer.Close())
+}
@@ -0,0 +1,69 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gosom/scrapemate"
+)
+
+// Reader reads the records of a .warc or .warc.gz stream
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader creates a Reader. Gzip compressed input is detected.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+
+		br = bufio.NewReader(zr)
+	}
+
+	return &Reader{r: br}, nil
+}
+
+// Next returns the next record or io.EOF
+func (r *Reader) Next() (*Record, error) {
+	return readRecord(r.r)
+}
+
+// ParseResponse converts a response record to a scrapemate.Response.
+// CachedAt is the date of the record and Truncated tells whether it has
+// a WARC-Truncated field.
+func ParseResponse(rec *Record) (scrapemate.Response, error) {
+	if rec.Type() != TypeResponse {
+		return scrapemate.Response{}, fmt.Errorf("%w: not a response record", ErrInvalidRecord)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(rec.Content)), nil)
+	if err != nil {
+		return scrapemate.Response{}, fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return scrapemate.Response{}, fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+
+	return scrapemate.Response{
+		URL:        rec.TargetURI(),
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Header,
+		Body:       body,
+		CachedAt:   rec.Date(),
+		Truncated:  rec.Header.Get("WARC-Truncated") != "",
+	}, nil
+}
@@ -0,0 +1,167 @@
+// Package warc writes fetched responses to WARC (ISO 28500) archives and
+// reads them back.
+//
+// A Writer appends request and response records to rotating .warc.gz
+// files, NewFetcher records everything an HTTPFetcher fetches and a Cache
+// serves the archived responses as a read-only scrapemate.Cacher:
+//
+//	w, err := warc.NewWriter("archive")
+//	fetcher := warc.NewFetcher(nethttp.New(client), w)
+//
+//	cache, err := warc.OpenCache("archive")
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // WARC digests are SHA-1 by convention
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Version is the WARC version written
+	Version = "WARC/1.1"
+
+	TypeWarcinfo = "warcinfo"
+	TypeRequest  = "request"
+	TypeResponse = "response"
+
+	// HeaderCacheKey is a non standard header of response records holding
+	// the scrapemate cache key of the job that fetched them
+	HeaderCacheKey = "WARC-Scrapemate-Cache-Key"
+
+	// maxRecordSize bounds the Content-Length of the records read
+	maxRecordSize = 1 << 30
+)
+
+// ErrInvalidRecord returned when the input is not a valid WARC record
+var ErrInvalidRecord = errors.New("invalid warc record")
+
+// Record is a WARC record
+type Record struct {
+	Header textproto.MIMEHeader
+	// Content is the record block, e.g. the HTTP message
+	Content []byte
+}
+
+// Type returns the WARC-Type of the record
+func (r *Record) Type() string {
+	return r.Header.Get("WARC-Type")
+}
+
+// ID returns the WARC-Record-ID of the record
+func (r *Record) ID() string {
+	return r.Header.Get("WARC-Record-ID")
+}
+
+// TargetURI returns the WARC-Target-URI of the record
+func (r *Record) TargetURI() string {
+	return r.Header.Get("WARC-Target-URI")
+}
+
+// Date returns the WARC-Date of the record or the zero time
+func (r *Record) Date() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, r.Header.Get("WARC-Date"))
+
+	return t
+}
+
+// field is a named field of a record header. Headers are written in order.
+type field struct {
+	name  string
+	value string
+}
+
+func writeRecord(w io.Writer, fields []field, content []byte) error {
+	var buf bytes.Buffer
+
+	buf.WriteString(Version + "\r\n")
+
+	for _, f := range fields {
+		buf.WriteString(f.name + ": " + f.value + "\r\n")
+	}
+
+	buf.WriteString("Content-Length: " + strconv.Itoa(len(content)) + "\r\n\r\n")
+	buf.Write(content)
+	buf.WriteString("\r\n\r\n")
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
+
+// readRecord reads the next record. It returns io.EOF when there are
+// no more records.
+func readRecord(r *bufio.Reader) (*Record, error) {
+	var line string
+
+	// skip blank lines between records
+	for line == "" {
+		l, err := r.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) && strings.TrimSpace(l) == "" {
+				return nil, io.EOF
+			}
+
+			return nil, fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+		}
+
+		line = strings.TrimRight(l, "\r\n")
+	}
+
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, fmt.Errorf("%w: unexpected version line %q", ErrInvalidRecord, line)
+	}
+
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("%w: invalid Content-Length", ErrInvalidRecord)
+	}
+
+	if length > maxRecordSize {
+		return nil, fmt.Errorf("%w: Content-Length %d exceeds %d", ErrInvalidRecord, length, maxRecordSize)
+	}
+
+	// the buffer grows with what is read, not with the declared length
+	var content bytes.Buffer
+	if _, err := io.CopyN(&content, r, length); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRecord, err)
+	}
+
+	return &Record{Header: header, Content: content.Bytes()}, nil
+}
+
+func newRecordID() string {
+	var b [16]byte
+
+	_, _ = rand.Read(b[:])
+
+	// version 4, variant RFC 4122
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func digest(data []byte) string {
+	sum := sha1.Sum(data) //nolint:gosec // WARC digests are SHA-1 by convention
+
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
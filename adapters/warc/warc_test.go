@@ -0,0 +1,180 @@
+package warc_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/warc"
+	"github.com/gosom/scrapemate/mock"
+)
+
+func TestWARC(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	w, err := warc.NewWriter(dir, warc.WithMaxFileSize(1500))
+	require.NoError(t, err)
+
+	inner := mock.NewMockHTTPFetcher(gomock.NewController(t))
+	fetcher := warc.NewFetcher(inner, w)
+
+	jobs := []*scrapemate.Job{
+		{Method: http.MethodGet, URL: "http://example.com/a", URLParams: map[string]string{"q": "1"}},
+		{Method: http.MethodPost, URL: "http://example.com/b", Body: []byte("x=1")},
+		{Method: http.MethodGet, URL: "http://example.com/c"},
+	}
+
+	for i, job := range jobs {
+		inner.EXPECT().Fetch(gomock.Any(), job).Return(scrapemate.Response{
+			URL:        job.GetFullURL(),
+			StatusCode: http.StatusOK,
+			Headers:    http.Header{"Content-Type": []string{"text/html"}},
+			Body:       []byte("<html>" + job.URL + "</html>"),
+		})
+
+		resp := fetcher.Fetch(ctx, job)
+		require.NoError(t, resp.Error, i)
+	}
+
+	failed := &scrapemate.Job{Method: http.MethodGet, URL: "http://example.com/failed"}
+	inner.EXPECT().Fetch(gomock.Any(), failed).Return(scrapemate.Response{Error: errors.New("timeout")})
+	require.Error(t, fetcher.Fetch(ctx, failed).Error)
+
+	inner.EXPECT().Close().Return(nil)
+	require.NoError(t, fetcher.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.warc.gz"))
+	require.NoError(t, err)
+	require.Greater(t, len(files), 1, "files should rotate")
+
+	t.Run("reader", func(t *testing.T) {
+		f, err := os.Open(files[0])
+		require.NoError(t, err)
+
+		defer f.Close()
+
+		r, err := warc.NewReader(f)
+		require.NoError(t, err)
+
+		var types []string
+
+		for {
+			rec, err := r.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			require.NoError(t, err)
+
+			types = append(types, rec.Type())
+		}
+
+		require.Equal(t, []string{warc.TypeWarcinfo, warc.TypeResponse, warc.TypeRequest}, types[:3])
+	})
+	t.Run("cache", func(t *testing.T) {
+		c, err := warc.OpenCache(dir)
+		require.NoError(t, err)
+		require.Equal(t, 3, c.Len())
+
+		for _, job := range jobs {
+			resp, err := c.Get(ctx, job.GetCacheKey())
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, job.GetFullURL(), resp.URL)
+			require.Equal(t, "<html>"+job.URL+"</html>", string(resp.Body))
+			require.Equal(t, "text/html", resp.Headers.Get("Content-Type"))
+			require.False(t, resp.CachedAt.IsZero())
+		}
+
+		_, err = c.Get(ctx, failed.GetCacheKey())
+		require.ErrorIs(t, err, scrapemate.ErrCacheMiss)
+
+		require.ErrorIs(t, c.Set(ctx, "key", &scrapemate.Response{}), warc.ErrReadOnly)
+	})
+}
+
+func TestWriter_redirectedTruncated(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := warc.NewWriter(dir)
+	require.NoError(t, err)
+
+	job := &scrapemate.Job{Method: http.MethodGet, URL: "http://example.com/old"}
+
+	require.NoError(t, w.Write(job, &scrapemate.Response{
+		URL:        "http://example.com/new",
+		StatusCode: http.StatusOK,
+		Body:       []byte("<html>"),
+		Truncated:  true,
+	}))
+	require.NoError(t, w.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.warc.gz"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	r, err := warc.NewReader(f)
+	require.NoError(t, err)
+
+	targets := map[string]string{}
+
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		require.NoError(t, err)
+
+		targets[rec.Type()] = rec.TargetURI()
+
+		if rec.Type() == warc.TypeResponse {
+			require.Equal(t, "length", rec.Header.Get("WARC-Truncated"))
+
+			resp, err := warc.ParseResponse(rec)
+			require.NoError(t, err)
+			require.True(t, resp.Truncated)
+		}
+	}
+
+	require.Equal(t, "http://example.com/new", targets[warc.TypeResponse])
+	require.Equal(t, "http://example.com/old", targets[warc.TypeRequest])
+}
+
+func TestReader_malformed(t *testing.T) {
+	const header = "WARC/1.1\r\nWARC-Type: response\r\n"
+
+	tests := map[string]string{
+		"no version":        "HTTP/1.1 200 OK\r\n\r\n",
+		"no length":         header + "\r\n",
+		"invalid length":    header + "Content-Length: abc\r\n\r\n",
+		"negative length":   header + "Content-Length: -1\r\n\r\n",
+		"too large length":  header + "Content-Length: 9223372036854775807\r\n\r\n",
+		"truncated content": header + "Content-Length: 1000\r\n\r\nshort",
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			r, err := warc.NewReader(strings.NewReader(data))
+			require.NoError(t, err)
+
+			_, err = r.Next()
+			require.ErrorIs(t, err, warc.ErrInvalidRecord)
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosom/scrapemate"
+)
+
+const defaultMaxFileSize = 1 << 30
+
+// WriterOption configures a Writer
+type WriterOption func(*Writer)
+
+// WithPrefix sets the prefix of the file names (default "scrapemate")
+func WithPrefix(prefix string) WriterOption {
+	return func(w *Writer) {
+		w.prefix = prefix
+	}
+}
+
+// WithMaxFileSize sets the size after which a new file is started
+// (default 1GiB)
+func WithMaxFileSize(size int64) WriterOption {
+	return func(w *Writer) {
+		w.maxFileSize = size
+	}
+}
+
+// Writer writes request and response records to rotating .warc.gz files
+// named <prefix>-<timestamp>-<serial>.warc.gz. Every record is a separate
+// gzip member so the files can be read from any record offset.
+// It's safe for concurrent use.
+type Writer struct {
+	dir         string
+	prefix      string
+	maxFileSize int64
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	serial int
+}
+
+// NewWriter creates a Writer for the directory dir
+func NewWriter(dir string, options ...WriterOption) (*Writer, error) {
+	const permissions = 0o777
+	if err := os.MkdirAll(dir, permissions); err != nil {
+		return nil, fmt.Errorf("cannot create warc dir %w", err)
+	}
+
+	w := Writer{
+		dir:         dir,
+		prefix:      "scrapemate",
+		maxFileSize: defaultMaxFileSize,
+	}
+
+	for _, opt := range options {
+		opt(&w)
+	}
+
+	return &w, nil
+}
+
+// Write records the request of job and its response. The response record
+// targets the final URL of the response, after redirects, and truncated
+// bodies are marked with WARC-Truncated.
+func (w *Writer) Write(job scrapemate.IJob, resp *scrapemate.Response) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	target := job.GetFullURL()
+	responseID := newRecordID()
+
+	respTarget := resp.URL
+	if respTarget == "" {
+		respTarget = target
+	}
+
+	respBlock := responseBlock(resp)
+
+	respFields := []field{
+		{"WARC-Type", TypeResponse},
+		{"WARC-Record-ID", responseID},
+		{"WARC-Date", now},
+		{"WARC-Target-URI", respTarget},
+		{"Content-Type", "application/http;msgtype=response"},
+		{"WARC-Block-Digest", digest(respBlock)},
+		{"WARC-Payload-Digest", digest(resp.Body)},
+		{HeaderCacheKey, job.GetCacheKey()},
+	}
+
+	if resp.Truncated {
+		respFields = append(respFields, field{"WARC-Truncated", "length"})
+	}
+
+	var buf bytes.Buffer
+
+	err := gzipRecord(&buf, respFields, respBlock)
+	if err != nil {
+		return err
+	}
+
+	reqBlock, err := requestBlock(job, target)
+	if err != nil {
+		return err
+	}
+
+	err = gzipRecord(&buf, []field{
+		{"WARC-Type", TypeRequest},
+		{"WARC-Record-ID", newRecordID()},
+		{"WARC-Date", now},
+		{"WARC-Target-URI", target},
+		{"WARC-Concurrent-To", responseID},
+		{"Content-Type", "application/http;msgtype=request"},
+		{"WARC-Block-Digest", digest(reqBlock)},
+	}, reqBlock)
+	if err != nil {
+		return err
+	}
+
+	return w.write(buf.Bytes())
+}
+
+// Close closes the current file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+
+	return err
+}
+
+func (w *Writer) write(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file != nil && w.size+int64(len(data)) > w.maxFileSize {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+
+		w.file = nil
+	}
+
+	if w.file == nil {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.file.Write(data)
+	w.size += int64(n)
+
+	return err
+}
+
+func (w *Writer) rotate() error {
+	w.serial++
+
+	name := fmt.Sprintf("%s-%s-%05d.warc.gz", w.prefix, time.Now().UTC().Format("20060102150405"), w.serial)
+
+	f, err := os.OpenFile(filepath.Join(w.dir, name), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("cannot create warc file %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	info := []byte("software: scrapemate\r\nformat: WARC File Format 1.1\r\n")
+
+	err = gzipRecord(&buf, []field{
+		{"WARC-Type", TypeWarcinfo},
+		{"WARC-Record-ID", newRecordID()},
+		{"WARC-Date", time.Now().UTC().Format(time.RFC3339)},
+		{"WARC-Filename", name},
+		{"Content-Type", "application/warc-fields"},
+	}, info)
+	if err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	n, err := f.Write(buf.Bytes())
+	if err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	w.file = f
+	w.size = int64(n)
+
+	return nil
+}
+
+func gzipRecord(buf *bytes.Buffer, fields []field, content []byte) error {
+	zw := gzip.NewWriter(buf)
+
+	if err := writeRecord(zw, fields, content); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// hop-by-hop and framing headers that don't describe the recorded body
+var skipHeaders = map[string]bool{
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+func responseBlock(resp *scrapemate.Response) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "HTTP/1.1 %d %s\r\n", resp.StatusCode, http.StatusText(resp.StatusCode))
+	writeHeaders(&buf, resp.Headers)
+	buf.WriteString("\r\n")
+	buf.Write(resp.Body)
+
+	return buf.Bytes()
+}
+
+func requestBlock(job scrapemate.IJob, target string) ([]byte, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %s: %w", target, err)
+	}
+
+	method := job.GetMethod()
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	headers := make(http.Header, len(job.GetHeaders())+1)
+	for k, v := range job.GetHeaders() {
+		headers.Set(k, v)
+	}
+
+	if headers.Get("Host") == "" {
+		headers.Set("Host", u.Host)
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "%s %s HTTP/1.1\r\n", method, u.RequestURI())
+	writeHeaders(&buf, headers)
+	buf.WriteString("\r\n")
+	buf.Write(job.GetBody())
+
+	return buf.Bytes(), nil
+}
+
+func writeHeaders(buf *bytes.Buffer, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if skipHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+
+		for _, v := range h[k] {
+			buf.WriteString(k + ": " + strings.ReplaceAll(v, "\r\n", " ") + "\r\n")
+		}
+	}
+}
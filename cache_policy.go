@@ -0,0 +1,124 @@
+package scrapemate
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheMaxAgeProvider is an optional IJob capability that overrides the
+// max age set with WithCacheMaxAge for the job's cached response.
+// A zero duration falls back to the global max age.
+type CacheMaxAgeProvider interface {
+	GetCacheMaxAge() time.Duration
+}
+
+type cacheFreshness int
+
+const (
+	// freshnessUnknown no max age applies to the cached response
+	freshnessUnknown cacheFreshness = iota
+	freshnessFresh
+	freshnessStale
+)
+
+// cacheFreshness tells if a cached response may still be used.
+// When HTTP cache semantics are enabled the Cache-Control and Expires
+// headers of the response take precedence over the configured max age.
+// Responses without a CachedAt time are stale whenever a max age applies.
+func (s *ScrapeMate) cacheFreshness(job IJob, resp *Response, now time.Time) cacheFreshness {
+	maxAge := s.cacheMaxAge
+
+	if provider, ok := job.(CacheMaxAgeProvider); ok {
+		if jobMaxAge := provider.GetCacheMaxAge(); jobMaxAge > 0 {
+			maxAge = jobMaxAge
+		}
+	}
+
+	if s.httpCacheSemantics {
+		if lifetime, ok := httpFreshnessLifetime(resp.Headers, resp.CachedAt); ok {
+			maxAge = lifetime
+		} else if maxAge <= 0 {
+			return freshnessUnknown
+		}
+	} else if maxAge <= 0 {
+		return freshnessUnknown
+	}
+
+	if resp.CachedAt.IsZero() || now.Sub(resp.CachedAt) >= maxAge {
+		return freshnessStale
+	}
+
+	return freshnessFresh
+}
+
+// isNoStore returns true if the response must not be stored in a cache
+func isNoStore(h http.Header) bool {
+	_, ok := cacheControlDirectives(h)["no-store"]
+
+	return ok
+}
+
+// httpFreshnessLifetime returns the freshness lifetime of a response as
+// declared by its Cache-Control (max-age, no-cache, no-store) or Expires
+// headers. ok is false when the headers declare nothing.
+func httpFreshnessLifetime(h http.Header, storedAt time.Time) (lifetime time.Duration, ok bool) {
+	directives := cacheControlDirectives(h)
+
+	if _, noCache := directives["no-cache"]; noCache {
+		return 0, true
+	}
+
+	if _, noStore := directives["no-store"]; noStore {
+		return 0, true
+	}
+
+	if v, found := directives["max-age"]; found {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seconds < 0 {
+			return 0, true
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if expires := h.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			// invalid dates, like "0", mean already expired
+			return 0, true
+		}
+
+		base := storedAt
+
+		if date, err := http.ParseTime(h.Get("Date")); err == nil {
+			base = date
+		}
+
+		if lifetime := expiresAt.Sub(base); lifetime > 0 {
+			return lifetime, true
+		}
+
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func cacheControlDirectives(h http.Header) map[string]string {
+	ans := map[string]string{}
+
+	for _, value := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name == "" {
+				continue
+			}
+
+			ans[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+
+	return ans
+}
@@ -0,0 +1,124 @@
+package scrapemate
+
+import (
+	"bytes"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// MetaCharset is the Response.Meta key holding the charset the body was
+// detected in before it was transcoded to UTF-8
+const MetaCharset = "charset"
+
+const utf8Charset = "utf-8"
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// xmlDeclEncoding matches the encoding of an XML declaration
+var xmlDeclEncoding = regexp.MustCompile(`^(\s*<\?xml\s[^>]*?\bencoding\s*=\s*["'])([A-Za-z0-9._:-]+)(["'])`)
+
+// TranscodeToUTF8 detects the charset of resp.Body and converts it to UTF-8.
+// The charset is taken from a byte order mark, the charset parameter of the
+// Content-Type header, the encoding of an XML declaration or a
+// <meta charset> tag, in that order.
+// The detected charset is recorded in resp.Meta under MetaCharset and the
+// Content-Type header and the XML declaration are rewritten to declare
+// utf-8, so XML decoders don't decode the body again.
+// Bodies with a non textual content type are left untouched.
+func TranscodeToUTF8(resp *Response) error {
+	if len(resp.Body) == 0 {
+		return nil
+	}
+
+	contentType := resp.Headers.Get("Content-Type")
+	if !isTextContentType(contentType) {
+		return nil
+	}
+
+	enc, name, certain := charset.DetermineEncoding(resp.Body, contentType)
+
+	if !certain {
+		if m := xmlDeclEncoding.FindSubmatch(resp.Body); m != nil {
+			if e, n := charset.Lookup(string(m[2])); e != nil {
+				enc, name, certain = e, n, true
+			}
+		}
+	}
+
+	// DetermineEncoding only looks at the first 1024 bytes and falls back to
+	// windows-1252 when it finds no declaration there. Don't break UTF-8
+	// documents whose first non ASCII character comes later.
+	if !certain && name == "windows-1252" && utf8.Valid(resp.Body) {
+		name = utf8Charset
+	}
+
+	if resp.Meta == nil {
+		resp.Meta = make(map[string]any)
+	}
+
+	resp.Meta[MetaCharset] = name
+
+	if name == utf8Charset {
+		resp.Body = declareUTF8(bytes.TrimPrefix(resp.Body, utf8BOM))
+
+		return nil
+	}
+
+	body, err := enc.NewDecoder().Bytes(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	resp.Body = declareUTF8(body)
+
+	if contentType != "" {
+		if mediaType, params, err := mime.ParseMediaType(contentType); err == nil {
+			params["charset"] = utf8Charset
+			resp.Headers.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+		}
+	}
+
+	return nil
+}
+
+// declareUTF8 rewrites the encoding of the XML declaration of body, if
+// any, to UTF-8
+func declareUTF8(body []byte) []byte {
+	m := xmlDeclEncoding.FindSubmatchIndex(body)
+	if m == nil || strings.EqualFold(string(body[m[4]:m[5]]), utf8Charset) {
+		return body
+	}
+
+	ans := make([]byte, 0, len(body))
+	ans = append(ans, body[:m[4]]...)
+	ans = append(ans, "UTF-8"...)
+
+	return append(ans, body[m[5]:]...)
+}
+
+func isTextContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+
+	for _, s := range []string{"html", "xml", "json", "javascript"} {
+		if strings.Contains(mediaType, s) {
+			return true
+		}
+	}
+
+	return false
+}
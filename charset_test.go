@@ -0,0 +1,97 @@
+package scrapemate_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/parsers/xmlparser"
+)
+
+func TestTranscodeToUTF8(t *testing.T) {
+	t.Run("charset from header", func(t *testing.T) {
+		body, err := charmap.Windows1252.NewEncoder().String("<p>café</p>")
+		require.NoError(t, err)
+
+		resp := scrapemate.Response{
+			Headers: http.Header{"Content-Type": []string{"text/html; charset=windows-1252"}},
+			Body:    []byte(body),
+		}
+
+		require.NoError(t, scrapemate.TranscodeToUTF8(&resp))
+		require.Equal(t, "<p>café</p>", string(resp.Body))
+		require.Equal(t, "windows-1252", resp.Meta[scrapemate.MetaCharset])
+		require.Equal(t, "text/html; charset=utf-8", resp.Headers.Get("Content-Type"))
+	})
+	t.Run("charset from meta tag", func(t *testing.T) {
+		body, err := japanese.ShiftJIS.NewEncoder().String(`<html><head><meta charset="shift_jis"></head><body>日本語</body></html>`)
+		require.NoError(t, err)
+
+		resp := scrapemate.Response{
+			Headers: http.Header{"Content-Type": []string{"text/html"}},
+			Body:    []byte(body),
+		}
+
+		require.NoError(t, scrapemate.TranscodeToUTF8(&resp))
+		require.Contains(t, string(resp.Body), "日本語")
+		require.Equal(t, "shift_jis", resp.Meta[scrapemate.MetaCharset])
+	})
+	t.Run("utf-8 bom", func(t *testing.T) {
+		resp := scrapemate.Response{
+			Body: append([]byte{0xEF, 0xBB, 0xBF}, []byte("<p>ok</p>")...),
+		}
+
+		require.NoError(t, scrapemate.TranscodeToUTF8(&resp))
+		require.Equal(t, "<p>ok</p>", string(resp.Body))
+		require.Equal(t, "utf-8", resp.Meta[scrapemate.MetaCharset])
+	})
+	t.Run("late utf-8 is kept", func(t *testing.T) {
+		body := make([]byte, 2048)
+		for i := range body {
+			body[i] = ' '
+		}
+
+		body = append(body, []byte("ελληνικά")...)
+
+		resp := scrapemate.Response{Body: body}
+
+		require.NoError(t, scrapemate.TranscodeToUTF8(&resp))
+		require.Equal(t, body, resp.Body)
+		require.Equal(t, "utf-8", resp.Meta[scrapemate.MetaCharset])
+	})
+	t.Run("xml declaration", func(t *testing.T) {
+		body := []byte(`<?xml version="1.0" encoding="ISO-8859-1"?><rss><title>caf` + "\xe9" + `</title></rss>`)
+
+		for _, contentType := range []string{"application/rss+xml", "application/xml; charset=iso-8859-1"} {
+			resp := scrapemate.Response{
+				Headers: http.Header{"Content-Type": []string{contentType}},
+				Body:    body,
+			}
+
+			require.NoError(t, scrapemate.TranscodeToUTF8(&resp))
+			require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?><rss><title>café</title></rss>`, string(resp.Body))
+
+			doc, err := xmlparser.New().Parse(context.Background(), resp.Body)
+			require.NoError(t, err)
+
+			node, ok := doc.(*xmlparser.Node)
+			require.True(t, ok)
+			require.Equal(t, "café", node.FindOne("title").InnerText())
+		}
+	})
+	t.Run("binary content is untouched", func(t *testing.T) {
+		resp := scrapemate.Response{
+			Headers: http.Header{"Content-Type": []string{"image/png"}},
+			Body:    []byte{0x89, 0x50, 0x4E, 0x47},
+		}
+
+		require.NoError(t, scrapemate.TranscodeToUTF8(&resp))
+		require.Equal(t, []byte{0x89, 0x50, 0x4E, 0x47}, resp.Body)
+		require.Nil(t, resp.Meta)
+	})
+}
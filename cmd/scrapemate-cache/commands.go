@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gosom/scrapemate"
+	cachecodec "github.com/gosom/scrapemate/adapters/cache"
+)
+
+var errFound = errors.New("found")
+
+func runList(ctx context.Context, args []string, w io.Writer) error {
+	var (
+		cf    cacheFlags
+		match string
+	)
+
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&match, "match", "", "only list entries whose URL matches this regular expression")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	re, err := compileMatch(match)
+	if err != nil {
+		return err
+	}
+
+	c, err := cf.open(openReadOnly)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tSTATUS\tSIZE\tDATE\tURL")
+
+	err = c.Iterate(ctx, func(key string, value *scrapemate.Response) error {
+		if re != nil && !re.MatchString(value.URL) {
+			return nil
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", key, value.StatusCode, len(value.Body), formatTime(value.CachedAt), value.URL)
+
+		return nil
+	})
+	if err != nil && !isUndecodable(err) {
+		return err
+	}
+
+	if ferr := tw.Flush(); ferr != nil {
+		return ferr
+	}
+
+	return err
+}
+
+func runShow(ctx context.Context, args []string, w io.Writer) error {
+	var (
+		cf          cacheFlags
+		headersOnly bool
+	)
+
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	cf.register(fs)
+	fs.BoolVar(&headersOnly, "headers", false, "do not print the body")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("show needs a cache key or a URL")
+	}
+
+	c, err := cf.open(openReadOnly)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	ref := fs.Arg(0)
+
+	resp, err := c.Get(ctx, ref)
+	if err != nil {
+		// not a key, look it up by URL
+		err = c.Iterate(ctx, func(_ string, value *scrapemate.Response) error {
+			if value.URL != ref {
+				return nil
+			}
+
+			resp = *value
+
+			return errFound
+		})
+
+		if !errors.Is(err, errFound) {
+			if err != nil {
+				return err
+			}
+
+			return fmt.Errorf("no entry for %s", ref)
+		}
+
+		err = nil
+	}
+
+	fmt.Fprintf(w, "URL: %s\nStatus: %d\nCached at: %s\nDuration: %s\n",
+		resp.URL, resp.StatusCode, formatTime(resp.CachedAt), resp.Duration)
+
+	if resp.Error != nil {
+		fmt.Fprintln(w, "Error:", resp.Error)
+	}
+
+	if resp.Truncated {
+		fmt.Fprintln(w, "Truncated: true")
+	}
+
+	if resp.Job != nil {
+		fmt.Fprintf(w, "Job: %s %s %s (id %s)\n", resp.Job.Type, resp.Job.Method, resp.Job.URL, resp.Job.ID)
+	}
+
+	fmt.Fprintln(w)
+
+	keys := make([]string, 0, len(resp.Headers))
+	for k := range resp.Headers {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range resp.Headers[k] {
+			fmt.Fprintf(w, "%s: %s\n", k, v)
+		}
+	}
+
+	if !headersOnly {
+		fmt.Fprintln(w)
+
+		_, err = w.Write(resp.Body)
+	}
+
+	return err
+}
+
+func runDelete(ctx context.Context, args []string, w io.Writer) error {
+	var (
+		cf     cacheFlags
+		match  string
+		dryRun bool
+	)
+
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&match, "match", "", "delete entries whose URL matches this regular expression")
+	fs.BoolVar(&dryRun, "dry-run", false, "only print the entries that would be deleted")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if match == "" {
+		return errors.New("delete needs -match")
+	}
+
+	re, err := compileMatch(match)
+	if err != nil {
+		return err
+	}
+
+	c, err := cf.open(openExisting)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	var keys []string
+
+	err = c.Iterate(ctx, func(key string, value *scrapemate.Response) error {
+		if re.MatchString(value.URL) {
+			keys = append(keys, key)
+
+			fmt.Fprintln(w, key, value.URL)
+		}
+
+		return nil
+	})
+	if err != nil && !isUndecodable(err) {
+		return err
+	}
+
+	if dryRun {
+		fmt.Fprintf(w, "%d entries would be deleted\n", len(keys))
+
+		return err
+	}
+
+	// deleting after iterating keeps the iterators consistent
+	for _, key := range keys {
+		if err := c.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "%d entries deleted\n", len(keys))
+
+	return err
+}
+
+func runStats(ctx context.Context, args []string, w io.Writer) error {
+	var cf cacheFlags
+
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	cf.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := cf.open(openReadOnly)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "entries: %d\nbytes: %d\n", stats.Entries, stats.Bytes)
+
+	return nil
+}
+
+func runExport(ctx context.Context, args []string, w io.Writer) error {
+	var (
+		cf     cacheFlags
+		toType string
+		to     string
+	)
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&toType, "to-type", "leveldb", "destination cache type: file or leveldb")
+	fs.StringVar(&to, "to", "", "destination cache directory")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return copyCache(ctx, cf, cacheFlags{cacheType: toType, dir: to}, w)
+}
+
+func runImport(ctx context.Context, args []string, w io.Writer) error {
+	var (
+		cf       cacheFlags
+		fromType string
+		from     string
+	)
+
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	cf.register(fs)
+	fs.StringVar(&fromType, "from-type", "file", "source cache type: file or leveldb")
+	fs.StringVar(&from, "from", "", "source cache directory")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return copyCache(ctx, cacheFlags{cacheType: fromType, dir: from}, cf, w)
+}
+
+// copyCache copies every entry of src to dst keeping the keys and
+// store times
+func copyCache(ctx context.Context, src, dst cacheFlags, w io.Writer) error {
+	if src == dst {
+		return errors.New("source and destination are the same cache")
+	}
+
+	from, err := src.open(openReadOnly)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+
+	defer from.Close()
+
+	to, err := dst.open(openCreate)
+	if err != nil {
+		return fmt.Errorf("destination: %w", err)
+	}
+
+	defer to.Close()
+
+	var copied int
+
+	err = from.Iterate(ctx, func(key string, value *scrapemate.Response) error {
+		if err := to.Set(ctx, key, value); err != nil {
+			return fmt.Errorf("cannot copy %s: %w", key, err)
+		}
+
+		copied++
+
+		return nil
+	})
+	if err != nil && !isUndecodable(err) {
+		return err
+	}
+
+	fmt.Fprintf(w, "%d entries copied\n", copied)
+
+	return err
+}
+
+// isUndecodable reports whether err tells that Iterate skipped entries it
+// could not decode. The other entries were iterated so the commands
+// complete before returning it.
+func isUndecodable(err error) bool {
+	return errors.Is(err, cachecodec.ErrUndecodableEntries)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Local().Format(time.DateTime)
+}
@@ -0,0 +1,159 @@
+// Command scrapemate-cache inspects and migrates scrapemate caches.
+//
+// Usage:
+//
+//	scrapemate-cache list   -type file -dir cache [-match regexp]
+//	scrapemate-cache show   -type file -dir cache [-headers] KEY|URL
+//	scrapemate-cache delete -type file -dir cache -match regexp [-dry-run]
+//	scrapemate-cache stats  -type file -dir cache
+//	scrapemate-cache export -type file -dir cache -to-type leveldb -to out
+//	scrapemate-cache import -type leveldb -dir cache -from-type file -from in
+//
+// Entries can be selected by cache key or by URL. The -match flag is a
+// regular expression matched against the URL of the cached response.
+// Entries that cannot be decoded are skipped by list, delete, export and
+// import, which report them with a warning and a non-zero exit status.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/cache/filecache"
+	"github.com/gosom/scrapemate/adapters/cache/leveldbcache"
+)
+
+const usage = `usage: scrapemate-cache <command> [flags]
+
+commands:
+  list     list the entries with URL, status, size and date
+  show     print a decoded entry selected by key or URL
+  delete   delete the entries whose URL matches -match
+  stats    print the number of entries and their stored size
+  export   copy the entries to a cache of another type
+  import   copy the entries of a cache of another type into this one
+`
+
+type cache interface {
+	scrapemate.Cacher
+	scrapemate.CacheAdmin
+}
+
+// command runs a subcommand with its arguments writing its output to w
+type command func(ctx context.Context, args []string, w io.Writer) error
+
+// openMode tells how a command opens a cache
+type openMode int
+
+const (
+	// openReadOnly opens an existing cache without modifying it
+	openReadOnly openMode = iota
+	// openExisting opens an existing cache for writing
+	openExisting
+	// openCreate opens a cache for writing, creating it if needed
+	openCreate
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	commands := map[string]command{
+		"list":   runList,
+		"show":   runShow,
+		"delete": runDelete,
+		"stats":  runStats,
+		"export": runExport,
+		"import": runImport,
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := cmd(ctx, os.Args[2:], os.Stdout); err != nil {
+		// the other entries were processed, the undecodable ones are
+		// reported as a warning
+		if isUndecodable(err) {
+			fmt.Fprintln(os.Stderr, "warning:", err)
+		} else {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+
+		os.Exit(1)
+	}
+}
+
+// cacheFlags are the flags selecting the cache a command operates on
+type cacheFlags struct {
+	cacheType string
+	dir       string
+}
+
+func (f *cacheFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.cacheType, "type", "file", "cache type: file or leveldb")
+	fs.StringVar(&f.dir, "dir", "", "cache directory")
+}
+
+func (f *cacheFlags) open(mode openMode) (cache, error) {
+	return openCache(f.cacheType, f.dir, mode)
+}
+
+func openCache(cacheType, dir string, mode openMode) (cache, error) {
+	if dir == "" {
+		return nil, errors.New("the cache directory is required")
+	}
+
+	if mode != openCreate {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("cannot open cache: %w", err)
+		}
+	}
+
+	readOnly := mode == openReadOnly
+
+	switch cacheType {
+	case "file":
+		if readOnly {
+			return filecache.NewFileCache(dir, filecache.WithReadOnly())
+		}
+
+		return filecache.NewFileCache(dir)
+	case "leveldb":
+		if readOnly {
+			return leveldbcache.NewLevelDBCache(dir, leveldbcache.WithReadOnly())
+		}
+
+		return leveldbcache.NewLevelDBCache(dir)
+	default:
+		return nil, fmt.Errorf("unknown cache type %q", cacheType)
+	}
+}
+
+func compileMatch(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -match: %w", err)
+	}
+
+	return re, nil
+}
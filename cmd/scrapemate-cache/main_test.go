@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/gosom/scrapemate"
+	cachecodec "github.com/gosom/scrapemate/adapters/cache"
+)
+
+// newCache returns the directory of a cache of cacheType holding an entry
+// for http://example.com/a and one for http://example.com/b
+func newCache(t *testing.T, cacheType string) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "cache")
+
+	c, err := openCache(cacheType, dir, openCreate)
+	require.NoError(t, err)
+
+	for key, url := range map[string]string{"ka": "http://example.com/a", "kb": "http://example.com/b"} {
+		require.NoError(t, c.Set(context.Background(), key, &scrapemate.Response{
+			URL:        url,
+			StatusCode: 200,
+			Body:       []byte("body of " + url),
+		}))
+	}
+
+	require.NoError(t, c.Close())
+
+	return dir
+}
+
+func run(t *testing.T, cmd command, args ...string) (string, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+
+	err := cmd(context.Background(), args, &out)
+
+	return out.String(), err
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      command
+		args     []string
+		contains []string
+		excludes []string
+		// entries is the number of entries left in the cache
+		entries int
+	}{
+		{
+			name:     "list",
+			cmd:      runList,
+			contains: []string{"ka", "http://example.com/a", "kb", "http://example.com/b"},
+			entries:  2,
+		},
+		{
+			name:     "list matching",
+			cmd:      runList,
+			args:     []string{"-match", "/b$"},
+			contains: []string{"http://example.com/b"},
+			excludes: []string{"http://example.com/a"},
+			entries:  2,
+		},
+		{
+			name:     "show by key",
+			cmd:      runShow,
+			args:     []string{"ka"},
+			contains: []string{"URL: http://example.com/a", "Status: 200", "body of http://example.com/a"},
+			entries:  2,
+		},
+		{
+			name:     "show by URL without body",
+			cmd:      runShow,
+			args:     []string{"-headers", "http://example.com/b"},
+			contains: []string{"URL: http://example.com/b"},
+			excludes: []string{"body of"},
+			entries:  2,
+		},
+		{
+			name:     "stats",
+			cmd:      runStats,
+			contains: []string{"entries: 2"},
+			entries:  2,
+		},
+		{
+			name:     "delete dry run",
+			cmd:      runDelete,
+			args:     []string{"-match", "/a$", "-dry-run"},
+			contains: []string{"ka http://example.com/a", "1 entries would be deleted"},
+			entries:  2,
+		},
+		{
+			name:     "delete",
+			cmd:      runDelete,
+			args:     []string{"-match", "/a$"},
+			contains: []string{"1 entries deleted"},
+			entries:  1,
+		},
+	}
+
+	for _, cacheType := range []string{"file", "leveldb"} {
+		for _, tt := range tests {
+			t.Run(cacheType+" "+tt.name, func(t *testing.T) {
+				dir := newCache(t, cacheType)
+
+				out, err := run(t, tt.cmd, append([]string{"-type", cacheType, "-dir", dir}, tt.args...)...)
+				require.NoError(t, err)
+
+				for _, s := range tt.contains {
+					require.Contains(t, out, s)
+				}
+
+				for _, s := range tt.excludes {
+					require.NotContains(t, out, s)
+				}
+
+				out, err = run(t, runStats, "-type", cacheType, "-dir", dir)
+				require.NoError(t, err)
+				require.Contains(t, out, "entries: "+strconv.Itoa(tt.entries))
+			})
+		}
+	}
+}
+
+// addUndecodable adds an entry that cannot be decoded with key kc to the
+// cache of cacheType in dir
+func addUndecodable(t *testing.T, cacheType, dir string) {
+	t.Helper()
+
+	switch cacheType {
+	case "file":
+		c, err := openCache(cacheType, dir, openExisting)
+		require.NoError(t, err)
+		require.NoError(t, c.Set(context.Background(), "kc", &scrapemate.Response{URL: "http://example.com/c"}))
+		require.NoError(t, c.Close())
+
+		files, err := filepath.Glob(filepath.Join(dir, "*", "kc"))
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		require.NoError(t, os.WriteFile(files[0], []byte("garbage"), 0o600))
+	case "leveldb":
+		db, err := leveldb.OpenFile(dir, nil)
+		require.NoError(t, err)
+		require.NoError(t, db.Put([]byte("kc"), []byte("garbage"), nil))
+		require.NoError(t, db.Close())
+	}
+}
+
+func TestCommands_undecodable(t *testing.T) {
+	for _, cacheType := range []string{"file", "leveldb"} {
+		t.Run(cacheType, func(t *testing.T) {
+			dir := newCache(t, cacheType)
+			addUndecodable(t, cacheType, dir)
+
+			out, err := run(t, runList, "-type", cacheType, "-dir", dir)
+			require.ErrorIs(t, err, cachecodec.ErrUndecodableEntries)
+			require.Contains(t, out, "http://example.com/a")
+			require.Contains(t, out, "http://example.com/b")
+
+			out, err = run(t, runDelete, "-type", cacheType, "-dir", dir, "-match", "/a$")
+			require.ErrorIs(t, err, cachecodec.ErrUndecodableEntries)
+			require.Contains(t, out, "1 entries deleted")
+
+			out, err = run(t, runStats, "-type", cacheType, "-dir", dir)
+			require.NoError(t, err)
+			require.Contains(t, out, "entries: 2")
+
+			out, err = run(t, runExport, "-type", cacheType, "-dir", dir, "-to-type", "leveldb", "-to", filepath.Join(t.TempDir(), "out"))
+			require.ErrorIs(t, err, cachecodec.ErrUndecodableEntries)
+			require.Contains(t, out, "1 entries copied")
+		})
+	}
+}
+
+func TestCommands_copy(t *testing.T) {
+	src := newCache(t, "file")
+	dst := filepath.Join(t.TempDir(), "leveldb")
+
+	out, err := run(t, runExport, "-type", "file", "-dir", src, "-to-type", "leveldb", "-to", dst)
+	require.NoError(t, err)
+	require.Contains(t, out, "2 entries copied")
+
+	back := filepath.Join(t.TempDir(), "file")
+
+	out, err = run(t, runImport, "-type", "file", "-dir", back, "-from-type", "leveldb", "-from", dst)
+	require.NoError(t, err)
+	require.Contains(t, out, "2 entries copied")
+
+	out, err = run(t, runShow, "-type", "file", "-dir", back, "ka")
+	require.NoError(t, err)
+	require.Contains(t, out, "body of http://example.com/a")
+}
+
+func TestCommands_missingCache(t *testing.T) {
+	for _, cacheType := range []string{"file", "leveldb"} {
+		for name, cmd := range map[string]command{"list": runList, "stats": runStats, "show": runShow} {
+			t.Run(cacheType+" "+name, func(t *testing.T) {
+				dir := filepath.Join(t.TempDir(), "missing")
+
+				_, err := run(t, cmd, "-type", cacheType, "-dir", dir, "key")
+				require.Error(t, err)
+				require.NoDirExists(t, dir)
+			})
+		}
+	}
+}
+
+func TestCommands_readOnly(t *testing.T) {
+	// a flat cache written by an older version is read but not migrated
+	dir := t.TempDir()
+
+	data, err := json.Marshal(&scrapemate.Response{URL: "http://example.com/old", StatusCode: 200})
+	require.NoError(t, err)
+
+	compressed, err := cachecodec.Compress(data)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "oldkey"), compressed, 0o600))
+
+	out, err := run(t, runList, "-type", "file", "-dir", dir)
+	require.NoError(t, err)
+	require.Contains(t, out, "http://example.com/old")
+
+	out, err = run(t, runShow, "-type", "file", "-dir", dir, "oldkey")
+	require.NoError(t, err)
+	require.Contains(t, out, "Status: 200")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "oldkey", entries[0].Name())
+}
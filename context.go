@@ -2,6 +2,7 @@ package scrapemate
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/gosom/kit/logging"
 )
@@ -21,4 +22,35 @@ func ContextWithLogger(ctx context.Context, logger logging.Logger) context.Conte
 	return context.WithValue(ctx, contextKey("log"), logger)
 }
 
+// ContextWithRequestHeaders returns a new context carrying headers that
+// fetchers add to the outgoing request, overriding the job's headers.
+// scrapemate uses it to send conditional requests when revalidating
+// cached responses.
+func ContextWithRequestHeaders(ctx context.Context, headers http.Header) context.Context {
+	return context.WithValue(ctx, contextKey("requestHeaders"), headers)
+}
+
+// RequestHeadersFromContext returns the headers set by ContextWithRequestHeaders
+// or nil
+func RequestHeadersFromContext(ctx context.Context) http.Header {
+	headers, _ := ctx.Value(contextKey("requestHeaders")).(http.Header)
+
+	return headers
+}
+
 type contextKey string
+
+// ContextWithParentJob returns a new context carrying the job whose
+// Process returned the jobs being pushed. scrapemate passes it to
+// JobProvider.Push when pushing next jobs.
+func ContextWithParentJob(ctx context.Context, job IJob) context.Context {
+	return context.WithValue(ctx, contextKey("parentJob"), job)
+}
+
+// ParentJobFromContext returns the job set by ContextWithParentJob,
+// nil for jobs that were not returned by another job
+func ParentJobFromContext(ctx context.Context) IJob {
+	job, _ := ctx.Value(contextKey("parentJob")).(IJob)
+
+	return job
+}
@@ -0,0 +1,20 @@
+package scrapemate
+
+import "time"
+
+// DelayedJob is an optional IJob capability for jobs that must not run
+// before a given time. Providers hold such jobs until they are due instead
+// of handing them out to a worker that would have to sleep.
+type DelayedJob interface {
+	GetNotBefore() time.Time
+}
+
+// NotBefore returns the time before which job must not run,
+// the zero time when it's due right away
+func NotBefore(job IJob) time.Time {
+	if delayed, ok := job.(DelayedJob); ok {
+		return delayed.GetNotBefore()
+	}
+
+	return time.Time{}
+}
@@ -19,8 +19,24 @@ var (
 	ErrorNoHTMLParser = errors.New("no html parser set")
 	// ErrorNoCacher returned when you try to initialized with a nil Cacher
 	ErrorNoCacher = errors.New("no cacher set")
+	// ErrorInvalidCacheMaxAge returned when the cache max age is not positive
+	ErrorInvalidCacheMaxAge = errors.New("cache max age must be positive")
 	// ErrorNoCsvCapable returned when you try to write a csv file without a csv capable Data
 	ErrorNotCsvCapable = errors.New("not csv capable")
 	// ErrInactivityTimeout returned when the system exits because of inactivity
 	ErrInactivityTimeout = errors.New("inactivity timeout")
+	// ErrBodyTooLarge returned when a response body exceeds the configured MaxBodySize
+	ErrBodyTooLarge = errors.New("response body too large")
+	// ErrContentTypeNotAllowed returned when a response content type is not in the allowlist
+	ErrContentTypeNotAllowed = errors.New("content type not allowed")
+	// ErrUnknownJobType returned when a JobRegistry has no factory for a job type
+	ErrUnknownJobType = errors.New("unknown job type")
+	// ErrInvalidJobType returned when registering a job type that cannot be decoded
+	ErrInvalidJobType = errors.New("invalid job type")
+	// ErrInvalidJobEnvelope returned when an encoded job cannot be decoded
+	ErrInvalidJobEnvelope = errors.New("invalid job envelope")
+	// ErrCacheMiss returned in cache only mode for jobs without a cached response
+	ErrCacheMiss = errors.New("cache miss")
+	// ErrCacheNotIterable returned when the cache does not implement CacheAdmin
+	ErrCacheNotIterable = errors.New("cache does not support iteration")
 )
@@ -11,7 +11,12 @@ import (
 	"time"
 )
 
-var _ IJob = (*Job)(nil)
+var (
+	_ IJob                = (*Job)(nil)
+	_ ResponseLimiter     = (*Job)(nil)
+	_ CacheMaxAgeProvider = (*Job)(nil)
+	_ DelayedJob          = (*Job)(nil)
+)
 
 // IJob is a job to be processed by the scrapemate
 type IJob interface {
@@ -87,7 +92,8 @@ type Job struct {
 	// true: when the response is to be accepted
 	// false: when the response is to be rejected
 	// By default a response is accepted if status code is 200
-	CheckResponse func(resp *Response) bool
+	// It's not encoded by JobCodec implementations.
+	CheckResponse func(resp *Response) bool `json:"-"`
 	// RetryPolicy can be one of:
 	// RetryJob: to retry the job untl it's successful
 	// DiscardJob:for not accepted responses just discard them and do not retry the job
@@ -100,7 +106,31 @@ type Job struct {
 	MaxRetryDelay time.Duration
 	// TakeScreenshot if true takes a screenshot of the page
 	TakeScreenshot bool
-	Response       Response
+	// ResponseLimits overrides the fetcher's global response limits
+	// for this job. Zero fields fall back to the global ones.
+	ResponseLimits ResponseLimits
+	// CacheMaxAge overrides the max age of the cached response set with
+	// WithCacheMaxAge. Zero falls back to the global one.
+	CacheMaxAge time.Duration
+	// NotBefore delays the job: providers hold it until then.
+	// The zero time means the job is due right away.
+	NotBefore time.Time
+	Response  Response
+}
+
+// GetNotBefore returns the time before which the job must not run
+func (j *Job) GetNotBefore() time.Time {
+	return j.NotBefore
+}
+
+// GetCacheMaxAge returns the max age of the job's cached response
+func (j *Job) GetCacheMaxAge() time.Duration {
+	return j.CacheMaxAge
+}
+
+// GetResponseLimits returns the response limits of the job
+func (j *Job) GetResponseLimits() ResponseLimits {
+	return j.ResponseLimits
 }
 
 // ProcessOnFetchError returns true if the job should be processed even if the job failed
@@ -0,0 +1,204 @@
+package scrapemate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+var (
+	_ JobCodec = (*JobRegistry)(nil)
+	_ JobCodec = (*EnvelopeCodec)(nil)
+)
+
+// EnvelopeFormat is the format of the envelopes written by EnvelopeCodec
+type EnvelopeFormat byte
+
+const (
+	// EnvelopeJSON encodes jobs as JSON objects, see JobEnvelope
+	EnvelopeJSON EnvelopeFormat = iota
+	// EnvelopeBinary encodes jobs in a compact binary format:
+	//
+	//	"SMJ" | version (1 byte) | kind (1 byte) | type name length (uvarint) | type name | gob payload
+	EnvelopeBinary
+)
+
+// envelopeVersion is the version of the binary format
+const envelopeVersion = 1
+
+// envelopeMagic starts every binary envelope. JSON ones start with '{'.
+var envelopeMagic = []byte{'S', 'M', 'J'}
+
+// kinds of envelope payloads
+const (
+	payloadJob byte = iota
+	payloadDescriptor
+)
+
+// JobEnvelope is an encoded job along with its type name.
+// Job holds the job itself for types registered with
+// JobRegistry.RegisterType, Descriptor the request of any other job,
+// rebuilt by the JobFactory registered for its type.
+type JobEnvelope struct {
+	Type       string          `json:"type"`
+	Job        json.RawMessage `json:"job,omitempty"`
+	Descriptor *JobDescriptor  `json:"descriptor,omitempty"`
+}
+
+// EnvelopeCodec is a JobCodec encoding jobs in envelopes of a format.
+// It decodes envelopes of both formats.
+type EnvelopeCodec struct {
+	registry *JobRegistry
+	format   EnvelopeFormat
+}
+
+// Codec returns a JobCodec for the jobs of the registry writing envelopes
+// in format
+func (r *JobRegistry) Codec(format EnvelopeFormat) *EnvelopeCodec {
+	return &EnvelopeCodec{registry: r, format: format}
+}
+
+// EncodeJob encodes job in a JSON envelope
+func (r *JobRegistry) EncodeJob(job IJob) ([]byte, error) {
+	return r.Codec(EnvelopeJSON).EncodeJob(job)
+}
+
+// DecodeJob decodes a job encoded in an envelope of any format
+func (r *JobRegistry) DecodeJob(data []byte) (IJob, error) {
+	return r.Codec(EnvelopeJSON).DecodeJob(data)
+}
+
+// EncodeJob encodes job. Jobs whose type was not registered with
+// RegisterType are encoded as their descriptor.
+func (c *EnvelopeCodec) EncodeJob(job IJob) ([]byte, error) {
+	typeName, whole := c.registry.registeredType(job)
+	if !whole {
+		typeName = JobType(job)
+	}
+
+	switch c.format {
+	case EnvelopeJSON:
+		env := JobEnvelope{Type: typeName}
+
+		if whole {
+			data, err := json.Marshal(job)
+			if err != nil {
+				return nil, fmt.Errorf("cannot encode job %s: %w", typeName, err)
+			}
+
+			env.Job = data
+		} else {
+			env.Descriptor = DescribeJob(job)
+		}
+
+		return json.Marshal(&env)
+	case EnvelopeBinary:
+		var (
+			buf   bytes.Buffer
+			value any = DescribeJob(job)
+			kind      = payloadDescriptor
+		)
+
+		if whole {
+			value, kind = job, payloadJob
+		}
+
+		buf.Write(envelopeMagic)
+		buf.WriteByte(envelopeVersion)
+		buf.WriteByte(kind)
+		buf.Write(binary.AppendUvarint(nil, uint64(len(typeName))))
+		buf.WriteString(typeName)
+
+		if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+			return nil, fmt.Errorf("cannot encode job %s: %w", typeName, err)
+		}
+
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown envelope format %d", c.format)
+	}
+}
+
+// DecodeJob decodes a job encoded by EncodeJob in any format.
+// It returns ErrUnknownJobType when the type of the job is not registered.
+func (c *EnvelopeCodec) DecodeJob(data []byte) (IJob, error) {
+	if bytes.HasPrefix(data, envelopeMagic) {
+		return c.decodeBinary(data[len(envelopeMagic):])
+	}
+
+	var env JobEnvelope
+
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidJobEnvelope, err)
+	}
+
+	switch {
+	case env.Job != nil:
+		job, err := c.registry.newOfType(env.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(env.Job, job); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidJobEnvelope, err)
+		}
+
+		return job, nil
+	case env.Descriptor != nil:
+		env.Descriptor.Type = env.Type
+
+		return c.registry.New(env.Descriptor)
+	default:
+		return nil, fmt.Errorf("%w: no job", ErrInvalidJobEnvelope)
+	}
+}
+
+func (c *EnvelopeCodec) decodeBinary(data []byte) (IJob, error) {
+	const headerLen = 2
+	if len(data) < headerLen {
+		return nil, fmt.Errorf("%w: short header", ErrInvalidJobEnvelope)
+	}
+
+	if data[0] != envelopeVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidJobEnvelope, data[0])
+	}
+
+	kind := data[1]
+	data = data[headerLen:]
+
+	n, size := binary.Uvarint(data)
+	if size <= 0 || uint64(len(data)-size) < n {
+		return nil, fmt.Errorf("%w: bad type name", ErrInvalidJobEnvelope)
+	}
+
+	typeName := string(data[size : size+int(n)])
+	payload := bytes.NewReader(data[size+int(n):])
+
+	switch kind {
+	case payloadJob:
+		job, err := c.registry.newOfType(typeName)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := gob.NewDecoder(payload).Decode(job); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidJobEnvelope, err)
+		}
+
+		return job, nil
+	case payloadDescriptor:
+		var desc JobDescriptor
+
+		if err := gob.NewDecoder(payload).Decode(&desc); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidJobEnvelope, err)
+		}
+
+		desc.Type = typeName
+
+		return c.registry.New(&desc)
+	default:
+		return nil, fmt.Errorf("%w: unknown payload kind %d", ErrInvalidJobEnvelope, kind)
+	}
+}
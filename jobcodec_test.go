@@ -0,0 +1,89 @@
+package scrapemate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+)
+
+type detailJob struct {
+	scrapemate.Job
+	Category string
+	Page     int
+}
+
+func TestJobRegistry_Codec(t *testing.T) {
+	registry := scrapemate.NewJobRegistry()
+	require.NoError(t, registry.RegisterType("detail", &detailJob{}))
+
+	detail := &detailJob{
+		Job: scrapemate.Job{
+			ID:            "1",
+			Method:        "GET",
+			URL:           "http://example.com/book",
+			Headers:       map[string]string{"Accept": "text/html"},
+			Priority:      scrapemate.PriorityLow,
+			MaxRetries:    3,
+			Timeout:       time.Minute,
+			CheckResponse: func(*scrapemate.Response) bool { return true },
+		},
+		Category: "poetry",
+		Page:     2,
+	}
+
+	for _, format := range []scrapemate.EnvelopeFormat{scrapemate.EnvelopeJSON, scrapemate.EnvelopeBinary} {
+		codec := registry.Codec(format)
+
+		t.Run("registeredType", func(t *testing.T) {
+			data, err := codec.EncodeJob(detail)
+			require.NoError(t, err)
+
+			decoded, err := registry.DecodeJob(data)
+			require.NoError(t, err)
+			require.IsType(t, &detailJob{}, decoded)
+
+			want := *detail
+			want.CheckResponse = nil
+			require.Equal(t, &want, decoded)
+		})
+
+		t.Run("unknownType", func(t *testing.T) {
+			data, err := codec.EncodeJob(detail)
+			require.NoError(t, err)
+
+			_, err = scrapemate.NewJobRegistry().Codec(format).DecodeJob(data)
+			require.ErrorIs(t, err, scrapemate.ErrUnknownJobType)
+		})
+
+		t.Run("corrupt", func(t *testing.T) {
+			data, err := codec.EncodeJob(detail)
+			require.NoError(t, err)
+
+			_, err = codec.DecodeJob(data[:len(data)/2])
+			require.ErrorIs(t, err, scrapemate.ErrInvalidJobEnvelope)
+		})
+	}
+
+	t.Run("descriptor", func(t *testing.T) {
+		registry := scrapemate.NewJobRegistry()
+		registry.Register(scrapemate.JobType(&titleJob{}), func(desc *scrapemate.JobDescriptor) (scrapemate.IJob, error) {
+			return &titleJob{Job: desc.Job()}, nil
+		})
+
+		for _, format := range []scrapemate.EnvelopeFormat{scrapemate.EnvelopeJSON, scrapemate.EnvelopeBinary} {
+			data, err := registry.Codec(format).EncodeJob(&titleJob{Job: scrapemate.Job{ID: "2", URL: "http://example.com"}})
+			require.NoError(t, err)
+
+			decoded, err := registry.DecodeJob(data)
+			require.NoError(t, err)
+			require.Equal(t, &titleJob{Job: scrapemate.Job{ID: "2", URL: "http://example.com"}}, decoded)
+		}
+	})
+
+	t.Run("invalidType", func(t *testing.T) {
+		require.ErrorIs(t, registry.RegisterType("nil", nil), scrapemate.ErrInvalidJobType)
+	})
+}
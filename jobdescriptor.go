@@ -0,0 +1,176 @@
+package scrapemate
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// JobDescriptor describes the request of a job. It's stored along with
+// cached responses so the job that produced an entry can be told and
+// rebuilt from the cache.
+type JobDescriptor struct {
+	// Type is the job type, see JobType
+	Type      string            `json:"type"`
+	ID        string            `json:"id,omitempty"`
+	ParentID  string            `json:"parent_id,omitempty"`
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	URLParams map[string]string `json:"url_params,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      []byte            `json:"body,omitempty"`
+	Priority  int               `json:"priority,omitempty"`
+}
+
+// DescribeJob returns the descriptor of job
+func DescribeJob(job IJob) *JobDescriptor {
+	return &JobDescriptor{
+		Type:      JobType(job),
+		ID:        job.GetID(),
+		ParentID:  job.GetParentID(),
+		Method:    job.GetMethod(),
+		URL:       job.GetURL(),
+		URLParams: job.GetURLParams(),
+		Headers:   job.GetHeaders(),
+		Body:      job.GetBody(),
+		Priority:  job.GetPriority(),
+	}
+}
+
+// Job returns a Job with the request fields of the descriptor.
+// Job factories usually embed it in their own job types.
+func (d *JobDescriptor) Job() Job {
+	return Job{
+		ID:        d.ID,
+		ParentID:  d.ParentID,
+		Method:    d.Method,
+		URL:       d.URL,
+		URLParams: d.URLParams,
+		Headers:   d.Headers,
+		Body:      d.Body,
+		Priority:  d.Priority,
+	}
+}
+
+// TypedJob is an optional IJob capability to name the job type.
+// Names must be stable across builds since they are persisted.
+type TypedJob interface {
+	JobType() string
+}
+
+// JobType returns the type name of job: the one returned by JobType for
+// TypedJob implementations, or the Go type name (e.g. "main.BookJob").
+func JobType(job IJob) string {
+	if typed, ok := job.(TypedJob); ok {
+		return typed.JobType()
+	}
+
+	t := reflect.TypeOf(job)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t.String()
+}
+
+// JobFactory rebuilds a job from its descriptor
+type JobFactory func(desc *JobDescriptor) (IJob, error)
+
+// JobRegistry maps job type names to the factories rebuilding them from
+// their descriptors and to the Go types registered with RegisterType.
+// As a JobCodec it encodes jobs in JSON envelopes, see Codec.
+type JobRegistry struct {
+	mu        sync.RWMutex
+	factories map[string]JobFactory
+	types     map[string]reflect.Type
+	names     map[reflect.Type]string
+}
+
+// NewJobRegistry creates an empty JobRegistry
+func NewJobRegistry() *JobRegistry {
+	return &JobRegistry{
+		factories: make(map[string]JobFactory),
+		types:     make(map[string]reflect.Type),
+		names:     make(map[reflect.Type]string),
+	}
+}
+
+// Register sets the factory for the job type typeName
+// replacing any previous one
+func (r *JobRegistry) Register(typeName string, factory JobFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.factories[typeName] = factory
+}
+
+// New rebuilds the job described by desc.
+// It returns ErrUnknownJobType if its type is not registered.
+func (r *JobRegistry) New(desc *JobDescriptor) (IJob, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[desc.Type]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJobType, desc.Type)
+	}
+
+	return factory(desc)
+}
+
+// RegisterType registers the type of prototype, a pointer to a struct
+// implementing IJob, under typeName replacing any previous one.
+// Jobs of registered types are encoded whole, all their exported fields
+// included, and decoded back to the same type.
+func (r *JobRegistry) RegisterType(typeName string, prototype IJob) error {
+	t := reflect.TypeOf(prototype)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %s is not a pointer to a struct", ErrInvalidJobType, typeName)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if old, ok := r.types[typeName]; ok {
+		delete(r.names, old)
+	}
+
+	r.types[typeName] = t.Elem()
+	r.names[t.Elem()] = typeName
+
+	return nil
+}
+
+// registeredType returns the name and the struct type of job
+// when its type was registered with RegisterType
+func (r *JobRegistry) registeredType(job IJob) (string, bool) {
+	t := reflect.TypeOf(job)
+	if t == nil || t.Kind() != reflect.Pointer {
+		return "", false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name, ok := r.names[t.Elem()]
+
+	return name, ok
+}
+
+// newOfType returns a new zero job of the type registered under typeName
+func (r *JobRegistry) newOfType(typeName string) (IJob, error) {
+	r.mu.RLock()
+	t, ok := r.types[typeName]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJobType, typeName)
+	}
+
+	job, ok := reflect.New(t).Interface().(IJob)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s does not implement IJob", ErrInvalidJobType, typeName)
+	}
+
+	return job, nil
+}
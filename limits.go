@@ -0,0 +1,130 @@
+package scrapemate
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// ResponseLimits restricts what a fetcher accepts from a server.
+// The zero value means no limits.
+type ResponseLimits struct {
+	// MaxBodySize is the maximum number of (decoded) body bytes a fetcher
+	// reads. Zero means no limit.
+	MaxBodySize int64
+	// TruncateBody controls what happens when a body exceeds MaxBodySize.
+	// When true the body is cut at MaxBodySize and Response.Truncated is set.
+	// When false the fetch fails with ErrBodyTooLarge.
+	TruncateBody bool
+	// AllowedContentTypes is a list of media types that are accepted,
+	// for example "text/html" or "application/json". A trailing wildcard
+	// such as "text/*" matches every subtype. An empty list accepts everything.
+	AllowedContentTypes []string
+}
+
+// ResponseLimiter is an optional IJob capability that lets a job override the
+// fetcher's global ResponseLimits. Non zero fields of the returned value take
+// precedence over the global ones.
+type ResponseLimiter interface {
+	GetResponseLimits() ResponseLimits
+}
+
+// ResponseLimitsForJob returns the limits to apply when fetching job.
+// global are the limits configured on the fetcher.
+func ResponseLimitsForJob(global ResponseLimits, job IJob) ResponseLimits {
+	limiter, ok := job.(ResponseLimiter)
+	if !ok {
+		return global
+	}
+
+	override := limiter.GetResponseLimits()
+
+	if override.MaxBodySize > 0 {
+		global.MaxBodySize = override.MaxBodySize
+		global.TruncateBody = override.TruncateBody
+	}
+
+	if len(override.AllowedContentTypes) > 0 {
+		global.AllowedContentTypes = override.AllowedContentTypes
+	}
+
+	return global
+}
+
+// IsZero returns true when no limit is set
+func (l ResponseLimits) IsZero() bool {
+	return l.MaxBodySize <= 0 && len(l.AllowedContentTypes) == 0
+}
+
+// CheckContentType returns an error wrapping ErrContentTypeNotAllowed
+// if contentType is not in the allowlist.
+func (l ResponseLimits) CheckContentType(contentType string) error {
+	if len(l.AllowedContentTypes) == 0 {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+
+	for _, allowed := range l.AllowedContentTypes {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return nil
+			}
+
+			continue
+		}
+
+		if allowed == "*/*" || allowed == mediaType {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %q", ErrContentTypeNotAllowed, contentType)
+}
+
+// CheckContentLength returns ErrBodyTooLarge when the announced content
+// length exceeds MaxBodySize and the body may not be truncated.
+// A negative contentLength means unknown and is always accepted.
+func (l ResponseLimits) CheckContentLength(contentLength int64) error {
+	if l.MaxBodySize <= 0 || l.TruncateBody || contentLength < 0 {
+		return nil
+	}
+
+	if contentLength > l.MaxBodySize {
+		return fmt.Errorf("%w: content length %d exceeds %d bytes", ErrBodyTooLarge, contentLength, l.MaxBodySize)
+	}
+
+	return nil
+}
+
+// ReadBody reads r honoring MaxBodySize. It returns truncated=true when the
+// body was cut because TruncateBody is set, and an error wrapping
+// ErrBodyTooLarge when it is not.
+func (l ResponseLimits) ReadBody(r io.Reader) (body []byte, truncated bool, err error) {
+	if l.MaxBodySize <= 0 {
+		body, err = io.ReadAll(r)
+
+		return body, false, err
+	}
+
+	body, err = io.ReadAll(io.LimitReader(r, l.MaxBodySize+1))
+	if err != nil {
+		return body, false, err
+	}
+
+	if int64(len(body)) <= l.MaxBodySize {
+		return body, false, nil
+	}
+
+	if !l.TruncateBody {
+		return nil, false, fmt.Errorf("%w: body exceeds %d bytes", ErrBodyTooLarge, l.MaxBodySize)
+	}
+
+	return body[:l.MaxBodySize], true, nil
+}
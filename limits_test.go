@@ -0,0 +1,81 @@
+package scrapemate_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+)
+
+func TestResponseLimitsForJob(t *testing.T) {
+	global := scrapemate.ResponseLimits{
+		MaxBodySize:         100,
+		AllowedContentTypes: []string{"text/html"},
+	}
+
+	t.Run("job without overrides uses global", func(t *testing.T) {
+		job := &scrapemate.Job{}
+		require.Equal(t, global, scrapemate.ResponseLimitsForJob(global, job))
+	})
+	t.Run("job overrides body size", func(t *testing.T) {
+		job := &scrapemate.Job{
+			ResponseLimits: scrapemate.ResponseLimits{MaxBodySize: 10, TruncateBody: true},
+		}
+		limits := scrapemate.ResponseLimitsForJob(global, job)
+		require.Equal(t, int64(10), limits.MaxBodySize)
+		require.True(t, limits.TruncateBody)
+		require.Equal(t, []string{"text/html"}, limits.AllowedContentTypes)
+	})
+	t.Run("job overrides content types", func(t *testing.T) {
+		job := &scrapemate.Job{
+			ResponseLimits: scrapemate.ResponseLimits{AllowedContentTypes: []string{"application/json"}},
+		}
+		limits := scrapemate.ResponseLimitsForJob(global, job)
+		require.Equal(t, int64(100), limits.MaxBodySize)
+		require.Equal(t, []string{"application/json"}, limits.AllowedContentTypes)
+	})
+}
+
+func TestResponseLimits_CheckContentType(t *testing.T) {
+	limits := scrapemate.ResponseLimits{
+		AllowedContentTypes: []string{"text/html", "application/*"},
+	}
+
+	require.NoError(t, limits.CheckContentType("text/html; charset=utf-8"))
+	require.NoError(t, limits.CheckContentType("application/json"))
+	require.ErrorIs(t, limits.CheckContentType("video/mp4"), scrapemate.ErrContentTypeNotAllowed)
+	require.ErrorIs(t, limits.CheckContentType(""), scrapemate.ErrContentTypeNotAllowed)
+	require.NoError(t, scrapemate.ResponseLimits{}.CheckContentType("video/mp4"))
+}
+
+func TestResponseLimits_ReadBody(t *testing.T) {
+	data := []byte("0123456789")
+
+	t.Run("no limit", func(t *testing.T) {
+		body, truncated, err := scrapemate.ResponseLimits{}.ReadBody(bytes.NewReader(data))
+		require.NoError(t, err)
+		require.False(t, truncated)
+		require.Equal(t, data, body)
+	})
+	t.Run("within limit", func(t *testing.T) {
+		limits := scrapemate.ResponseLimits{MaxBodySize: 10}
+		body, truncated, err := limits.ReadBody(bytes.NewReader(data))
+		require.NoError(t, err)
+		require.False(t, truncated)
+		require.Equal(t, data, body)
+	})
+	t.Run("too large", func(t *testing.T) {
+		limits := scrapemate.ResponseLimits{MaxBodySize: 5}
+		_, _, err := limits.ReadBody(bytes.NewReader(data))
+		require.ErrorIs(t, err, scrapemate.ErrBodyTooLarge)
+	})
+	t.Run("truncate", func(t *testing.T) {
+		limits := scrapemate.ResponseLimits{MaxBodySize: 5, TruncateBody: true}
+		body, truncated, err := limits.ReadBody(bytes.NewReader(data))
+		require.NoError(t, err)
+		require.True(t, truncated)
+		require.Equal(t, data[:5], body)
+	})
+}
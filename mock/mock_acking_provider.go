@@ -0,0 +1,99 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/gosom/scrapemate (interfaces: AckingProvider)
+//
+// Generated by this command:
+//
+//	mockgen -destination=mock/mock_acking_provider.go -package=mock . AckingProvider
+//
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+
+	scrapemate "github.com/gosom/scrapemate"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAckingProvider is a mock of AckingProvider interface.
+type MockAckingProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockAckingProviderMockRecorder
+	isgomock struct{}
+}
+
+// MockAckingProviderMockRecorder is the mock recorder for MockAckingProvider.
+type MockAckingProviderMockRecorder struct {
+	mock *MockAckingProvider
+}
+
+// NewMockAckingProvider creates a new mock instance.
+func NewMockAckingProvider(ctrl *gomock.Controller) *MockAckingProvider {
+	mock := &MockAckingProvider{ctrl: ctrl}
+	mock.recorder = &MockAckingProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAckingProvider) EXPECT() *MockAckingProviderMockRecorder {
+	return m.recorder
+}
+
+// Ack mocks base method.
+func (m *MockAckingProvider) Ack(ctx context.Context, job scrapemate.IJob) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ack", ctx, job)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ack indicates an expected call of Ack.
+func (mr *MockAckingProviderMockRecorder) Ack(ctx, job any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ack", reflect.TypeOf((*MockAckingProvider)(nil).Ack), ctx, job)
+}
+
+// Jobs mocks base method.
+func (m *MockAckingProvider) Jobs(ctx context.Context) (<-chan scrapemate.IJob, <-chan error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Jobs", ctx)
+	ret0, _ := ret[0].(<-chan scrapemate.IJob)
+	ret1, _ := ret[1].(<-chan error)
+	return ret0, ret1
+}
+
+// Jobs indicates an expected call of Jobs.
+func (mr *MockAckingProviderMockRecorder) Jobs(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Jobs", reflect.TypeOf((*MockAckingProvider)(nil).Jobs), ctx)
+}
+
+// Nack mocks base method.
+func (m *MockAckingProvider) Nack(ctx context.Context, job scrapemate.IJob, err error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Nack", ctx, job, err)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Nack indicates an expected call of Nack.
+func (mr *MockAckingProviderMockRecorder) Nack(ctx, job, err any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Nack", reflect.TypeOf((*MockAckingProvider)(nil).Nack), ctx, job, err)
+}
+
+// Push mocks base method.
+func (m *MockAckingProvider) Push(ctx context.Context, job scrapemate.IJob) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Push", ctx, job)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Push indicates an expected call of Push.
+func (mr *MockAckingProviderMockRecorder) Push(ctx, job any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Push", reflect.TypeOf((*MockAckingProvider)(nil).Push), ctx, job)
+}
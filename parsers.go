@@ -0,0 +1,66 @@
+package scrapemate
+
+import (
+	"mime"
+	"strings"
+)
+
+// ParserProvider is an optional IJob capability that lets a job choose
+// the parser for its response instead of the ones configured in scrapemate.
+// Returning nil falls back to the configured parsers.
+type ParserProvider interface {
+	GetParser() HTMLParser
+}
+
+// parserRegistry maps media types to parsers
+type parserRegistry map[string]HTMLParser
+
+// lookup returns the parser registered for contentType.
+// It tries the exact media type, then the structured syntax suffix
+// (application/ld+json is handled by the application/json parser) and
+// finally a type wildcard such as text/*.
+func (r parserRegistry) lookup(contentType string) HTMLParser {
+	if len(r) == 0 || contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	if p, ok := r[mediaType]; ok {
+		return p
+	}
+
+	if i := strings.LastIndexByte(mediaType, '+'); i != -1 {
+		if p, ok := r["application/"+mediaType[i+1:]]; ok {
+			return p
+		}
+	}
+
+	if i := strings.IndexByte(mediaType, '/'); i != -1 {
+		if p, ok := r[mediaType[:i]+"/*"]; ok {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// parserFor returns the parser to use for the response of job.
+// The job's own parser takes precedence, then the one registered
+// for the response content type and finally the default html parser.
+func (s *ScrapeMate) parserFor(job IJob, resp *Response) HTMLParser {
+	if provider, ok := job.(ParserProvider); ok {
+		if p := provider.GetParser(); p != nil {
+			return p
+		}
+	}
+
+	if p := s.parsers.lookup(resp.Headers.Get("Content-Type")); p != nil {
+		return p
+	}
+
+	return s.htmlParser
+}
@@ -0,0 +1,122 @@
+package scrapemate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// Reprocess runs Process again for every cached response without touching
+// the network. Jobs are rebuilt from the descriptors stored with the
+// responses using registry. Entries without a descriptor are skipped and
+// the ones whose job cannot be rebuilt count as failed. Next jobs returned
+// by Process are
+// discarded since their responses are reprocessed from the cache as well.
+//
+// Results are sent to Results() like Start does and it's closed when
+// Reprocess returns. Use either Start or Reprocess, not both.
+// The cache must implement CacheAdmin.
+func (s *ScrapeMate) Reprocess(registry *JobRegistry) error {
+	defer func() {
+		close(s.results)
+
+		if s.failedJobs != nil {
+			close(s.failedJobs)
+		}
+	}()
+
+	admin, ok := s.cache.(CacheAdmin)
+	if !ok {
+		return ErrCacheNotIterable
+	}
+
+	s.log.Info("reprocessing cached responses")
+
+	ctx := s.ctx
+	entries := make(chan *Response)
+
+	wg := sync.WaitGroup{}
+	wg.Add(s.concurrency)
+
+	for i := 0; i < s.concurrency; i++ {
+		go func() {
+			defer wg.Done()
+
+			for resp := range entries {
+				s.reprocessEntry(ctx, registry, resp)
+			}
+		}()
+	}
+
+	err := admin.Iterate(ctx, func(_ string, value *Response) error {
+		if value.Job == nil {
+			s.log.Debug("skipping cached response without job descriptor", "url", value.URL)
+
+			return nil
+		}
+
+		resp := *value
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case entries <- &resp:
+			return nil
+		}
+	})
+
+	close(entries)
+	wg.Wait()
+
+	completed, failed, _ := s.stats.getStats()
+	s.log.Info("reprocessing finished", "numOfJobsCompleted", completed, "numOfJobsFailed", failed)
+
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *ScrapeMate) reprocessEntry(ctx context.Context, registry *JobRegistry, resp *Response) {
+	job, err := registry.New(resp.Job)
+	if err != nil {
+		s.log.Error("cannot rebuild job", "error", err, "url", resp.URL)
+		s.stats.incJobsFailed()
+
+		return
+	}
+
+	ans, err := s.reprocessJob(ContextWithLogger(ctx, s.log.With("jobid", job.GetID())), job, resp)
+	if err != nil {
+		s.pushToFailedJobs(job)
+
+		return
+	}
+
+	s.stats.incJobsCompleted()
+
+	if job.UseInResults() {
+		select {
+		case <-ctx.Done():
+		case s.results <- Result{Job: job, Data: ans}:
+		}
+	}
+}
+
+// reprocessJob processes resp with job turning panics into errors like
+// DoJob does
+func (s *ScrapeMate) reprocessJob(ctx context.Context, job IJob, resp *Response) (ans any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while reprocessing job: %v: %s", r, debug.Stack())
+			s.log.Error("job finished", "job", job, "error", err, "status", "failed")
+		}
+	}()
+
+	ans, _, err = s.process(ctx, job, resp)
+
+	return ans, err
+}
@@ -0,0 +1,140 @@
+package scrapemate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/cache/filecache"
+)
+
+type titleJob struct {
+	scrapemate.Job
+}
+
+func (j *titleJob) Process(_ context.Context, resp *scrapemate.Response) (any, []scrapemate.IJob, error) {
+	next := &titleJob{Job: scrapemate.Job{URL: resp.URL + "/next"}}
+
+	return string(resp.Body), []scrapemate.IJob{next}, nil
+}
+
+type panicJob struct {
+	scrapemate.Job
+}
+
+func (j *panicJob) Process(context.Context, *scrapemate.Response) (any, []scrapemate.IJob, error) {
+	panic("broken parser")
+}
+
+func Test_Reprocess(t *testing.T) {
+	ctx := context.Background()
+	svc := getMockedServices(t)
+
+	cache, err := filecache.NewFileCache(t.TempDir())
+	require.NoError(t, err)
+
+	mate, err := scrapemate.New(
+		scrapemate.WithHTTPFetcher(svc.fetcher),
+		scrapemate.WithJobProvider(svc.provider),
+		scrapemate.WithCache(cache),
+	)
+	require.NoError(t, err)
+
+	job := &titleJob{Job: scrapemate.Job{
+		ID:      "1",
+		Method:  "GET",
+		URL:     "http://example.com",
+		Headers: map[string]string{"Accept": "text/html"},
+	}}
+
+	svc.fetcher.EXPECT().Fetch(gomock.Any(), job).Return(scrapemate.Response{
+		URL:        "http://example.com",
+		StatusCode: 200,
+		Body:       []byte("title"),
+	})
+
+	_, _, err = mate.DoJob(ctx, job)
+	require.NoError(t, err)
+
+	cached, err := cache.Get(ctx, job.GetCacheKey())
+	require.NoError(t, err)
+	require.Equal(t, &scrapemate.JobDescriptor{
+		Type:    "scrapemate_test.titleJob",
+		ID:      "1",
+		Method:  "GET",
+		URL:     "http://example.com",
+		Headers: map[string]string{"Accept": "text/html"},
+	}, cached.Job)
+
+	t.Run("not iterable cache", func(t *testing.T) {
+		mate, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithCache(svc.cache),
+		)
+		require.NoError(t, err)
+
+		require.ErrorIs(t, mate.Reprocess(scrapemate.NewJobRegistry()), scrapemate.ErrCacheNotIterable)
+	})
+	t.Run("reprocess", func(t *testing.T) {
+		// no fetcher or provider calls are expected
+		mate, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithCache(cache),
+			scrapemate.WithConcurrency(2),
+		)
+		require.NoError(t, err)
+
+		registry := scrapemate.NewJobRegistry()
+		registry.Register("scrapemate_test.titleJob", func(desc *scrapemate.JobDescriptor) (scrapemate.IJob, error) {
+			return &titleJob{Job: desc.Job()}, nil
+		})
+
+		errc := make(chan error, 1)
+
+		go func() {
+			errc <- mate.Reprocess(registry)
+		}()
+
+		var results []scrapemate.Result
+		for result := range mate.Results() {
+			results = append(results, result)
+		}
+
+		require.NoError(t, <-errc)
+		require.Len(t, results, 1)
+		require.Equal(t, "title", results[0].Data)
+		require.Equal(t, "1", results[0].Job.GetID())
+		require.Equal(t, "text/html", results[0].Job.GetHeaders()["Accept"])
+	})
+
+	t.Run("panicking job", func(t *testing.T) {
+		mate, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithCache(cache),
+		)
+		require.NoError(t, err)
+
+		registry := scrapemate.NewJobRegistry()
+		registry.Register("scrapemate_test.titleJob", func(desc *scrapemate.JobDescriptor) (scrapemate.IJob, error) {
+			return &panicJob{Job: desc.Job()}, nil
+		})
+
+		errc := make(chan error, 1)
+
+		go func() {
+			errc <- mate.Reprocess(registry)
+		}()
+
+		for range mate.Results() {
+			require.Fail(t, "no result expected")
+		}
+
+		require.NoError(t, <-errc)
+	})
+}
@@ -5,6 +5,11 @@ import (
 	"time"
 )
 
+// MetaContentEncoding is the Response.Meta key holding the Content-Encoding
+// a fetcher decoded the body from. The decoded response no longer carries
+// the Content-Encoding header.
+const MetaContentEncoding = "content_encoding"
+
 // Response is the struct that it is returned when crawling finishes
 type Response struct {
 	URL        string
@@ -15,6 +20,15 @@ type Response struct {
 	Error      error
 	Meta       map[string]any
 	Screenshot []byte
+	// Truncated is true when the body was cut because it exceeded
+	// the MaxBodySize of the ResponseLimits in use
+	Truncated bool
+	// CachedAt is when the response was stored in the cache.
+	// It's zero for responses that were never cached.
+	CachedAt time.Time
+	// Job describes the job that requested the response.
+	// It's set on cached responses.
+	Job *JobDescriptor `json:",omitempty"`
 
 	// Document is the parsed document
 	// if you don't set an html parser the document will be nil
@@ -0,0 +1,54 @@
+package scrapemate
+
+import (
+	"net/http"
+)
+
+// conditionalHeaders builds the validators of a conditional request from
+// the headers of a cached response. It returns nil if the cached response
+// has neither an ETag nor a Last-Modified header.
+func conditionalHeaders(cached http.Header) http.Header {
+	var ans http.Header
+
+	if etag := cached.Get("ETag"); etag != "" {
+		ans = http.Header{}
+		ans.Set("If-None-Match", etag)
+	}
+
+	if lastModified := cached.Get("Last-Modified"); lastModified != "" {
+		if ans == nil {
+			ans = http.Header{}
+		}
+
+		ans.Set("If-Modified-Since", lastModified)
+	}
+
+	return ans
+}
+
+// notModifiedSkipHeaders are the headers of a 304 response that describe
+// the (empty) 304 body and must not overwrite the stored ones
+var notModifiedSkipHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Content-Encoding":  true,
+	"Content-Type":      true,
+	"Transfer-Encoding": true,
+}
+
+// refreshNotModified updates a cached response with the headers of a 304
+// Not Modified response, as described in RFC 9111 section 4.3.4.
+func refreshNotModified(cached, notModified *Response) {
+	if cached.Headers == nil {
+		cached.Headers = http.Header{}
+	}
+
+	for k, v := range notModified.Headers {
+		if notModifiedSkipHeaders[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+
+		cached.Headers[k] = v
+	}
+
+	cached.Duration = notModified.Duration
+}
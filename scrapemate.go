@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"mime"
+	"net/http"
 	"os"
 	"os/signal"
 	"runtime/debug"
@@ -28,9 +30,14 @@ func New(options ...func(*ScrapeMate) error) (*ScrapeMate, error) {
 		return nil, ErrorNoJobProvider
 	}
 
-	if s.httpFetcher == nil {
+	// nothing is fetched in cache only mode
+	if s.httpFetcher == nil && !s.cacheOnly {
 		return nil, ErrorNoHTMLFetcher
 	}
+
+	if s.cacheOnly && s.cache == nil {
+		return nil, ErrorNoCacher
+	}
 	// here we can set default options
 	s.results = make(chan Result)
 
@@ -150,6 +157,32 @@ func WithHTMLParser(parser HTMLParser) func(*ScrapeMate) error {
 	}
 }
 
+// WithParser registers a parser for responses whose Content-Type has the
+// given media type (e.g. "application/json"). A wildcard subtype such as
+// "text/*" matches every subtype and "application/json" also handles
+// structured syntax suffixes like "application/ld+json".
+// Responses that match no registered parser use the one set by WithHTMLParser.
+func WithParser(mimeType string, parser HTMLParser) func(*ScrapeMate) error {
+	return func(s *ScrapeMate) error {
+		if parser == nil {
+			return ErrorNoHTMLParser
+		}
+
+		mediaType, _, err := mime.ParseMediaType(mimeType)
+		if err != nil {
+			return fmt.Errorf("invalid mime type %q: %w", mimeType, err)
+		}
+
+		if s.parsers == nil {
+			s.parsers = make(parserRegistry)
+		}
+
+		s.parsers[mediaType] = parser
+
+		return nil
+	}
+}
+
 // WithCache sets the cache for the scrapemate
 func WithCache(cache Cacher) func(*ScrapeMate) error {
 	return func(s *ScrapeMate) error {
@@ -163,6 +196,84 @@ func WithCache(cache Cacher) func(*ScrapeMate) error {
 	}
 }
 
+// WithCharsetTranscoding enables converting response bodies to UTF-8
+// before they are parsed and processed.
+// The charset is detected from the Content-Type header, a BOM or a
+// <meta charset> tag and the original one is recorded in
+// Response.Meta[MetaCharset]. Cached responses keep their original bytes.
+func WithCharsetTranscoding() func(*ScrapeMate) error {
+	return func(s *ScrapeMate) error {
+		s.transcodeCharset = true
+
+		return nil
+	}
+}
+
+// WithCacheRevalidation makes scrapemate revalidate cached responses instead
+// of serving them blindly. If a cached response has an ETag or a Last-Modified
+// header a conditional request (If-None-Match / If-Modified-Since) is sent;
+// a 304 Not Modified answer is treated as a cache hit and the entry is stored
+// again to refresh it. Cached responses without validators are fetched again.
+// The fetcher must honor RequestHeadersFromContext (nethttp and stealth do).
+func WithCacheRevalidation() func(*ScrapeMate) error {
+	return func(s *ScrapeMate) error {
+		s.revalidateCache = true
+
+		return nil
+	}
+}
+
+// WithCacheMaxAge sets how long cached responses are used.
+// Older entries are treated as cache misses, or revalidated when
+// WithCacheRevalidation is set. Jobs implementing CacheMaxAgeProvider
+// can override it. Entries without a stored time are considered expired.
+func WithCacheMaxAge(maxAge time.Duration) func(*ScrapeMate) error {
+	return func(s *ScrapeMate) error {
+		if maxAge <= 0 {
+			return ErrorInvalidCacheMaxAge
+		}
+
+		s.cacheMaxAge = maxAge
+
+		return nil
+	}
+}
+
+// WithHTTPCacheSemantics makes the cache honor the caching headers of
+// the responses: Cache-Control no-store responses are not cached and the
+// freshness declared by Cache-Control max-age, no-cache or Expires takes
+// precedence over the max age set with WithCacheMaxAge.
+func WithHTTPCacheSemantics() func(*ScrapeMate) error {
+	return func(s *ScrapeMate) error {
+		s.httpCacheSemantics = true
+
+		return nil
+	}
+}
+
+// WithCacheOnly serves every job from the cache and never fetches.
+// Jobs without a cached response fail with ErrCacheMiss. Cached responses
+// are used even if they are expired. It requires WithCache but no
+// WithHTTPFetcher.
+func WithCacheOnly() func(*ScrapeMate) error {
+	return func(s *ScrapeMate) error {
+		s.cacheOnly = true
+
+		return nil
+	}
+}
+
+// WithCacheOnlySkipMisses is like WithCacheOnly but jobs without a cached
+// response are skipped instead of failing.
+func WithCacheOnlySkipMisses() func(*ScrapeMate) error {
+	return func(s *ScrapeMate) error {
+		s.cacheOnly = true
+		s.skipCacheMisses = true
+
+		return nil
+	}
+}
+
 // WithInitJob sets the first job to be processed
 // It will be processed before the jobs from the job provider
 // It is useful if you want to start the scraper with a specific job
@@ -187,11 +298,19 @@ type ScrapeMate struct {
 	concurrency int
 	httpFetcher HTTPFetcher
 	htmlParser  HTMLParser
+	parsers     parserRegistry
 	cache       Cacher
 	results     chan Result
 	failedJobs  chan IJob
 	initJob     IJob
 
+	transcodeCharset   bool
+	revalidateCache    bool
+	httpCacheSemantics bool
+	cacheMaxAge        time.Duration
+	cacheOnly          bool
+	skipCacheMisses    bool
+
 	stats                    stats
 	exitOnInactivity         bool
 	exitOnInactivityDuration time.Duration
@@ -286,7 +405,9 @@ func (s *ScrapeMate) Start() error {
 }
 
 func (s *ScrapeMate) Close() error {
-	_ = s.httpFetcher.Close()
+	if s.httpFetcher != nil {
+		_ = s.httpFetcher.Close()
+	}
 
 	return nil
 }
@@ -355,11 +476,46 @@ func (s *ScrapeMate) DoJob(ctx context.Context, job IJob) (result any, next []IJ
 		}
 	}
 
+	if s.cacheOnly {
+		if !cached {
+			resp.Error = fmt.Errorf("%w: %s", ErrCacheMiss, job.GetFullURL())
+
+			return nil, nil, resp.Error
+		}
+
+		s.log.Debug("using cached response", "job", job)
+
+		return s.process(ctx, job, &resp)
+	}
+
+	var validators http.Header
+
+	if cached {
+		freshness := s.cacheFreshness(job, &resp, time.Now())
+
+		switch {
+		case freshness == freshnessStale && !s.revalidateCache:
+			s.log.Debug("cached response expired", "job", job)
+
+			cached = false
+		case freshness != freshnessFresh && s.revalidateCache:
+			validators = conditionalHeaders(resp.Headers)
+			cached = validators != nil
+		}
+	}
+
 	switch {
-	case cached:
+	case cached && validators == nil:
 		s.log.Debug("using cached response", "job", job)
 	default:
-		resp = s.doFetch(ctx, job)
+		if validators == nil {
+			resp = s.doFetch(ctx, job, false)
+		} else if s.revalidate(ctx, job, cacheKey, &resp, validators) {
+			s.log.Debug("cached response revalidated", "job", job)
+
+			break
+		}
+
 		if !job.ProcessOnFetchError() && resp.Error != nil {
 			err = resp.Error
 
@@ -368,23 +524,36 @@ func (s *ScrapeMate) DoJob(ctx context.Context, job IJob) (result any, next []IJ
 
 		// check if resp.Error is valid because we may ProcessOnFetchError
 		if resp.Error == nil && s.cache != nil {
-			if errCache := s.cache.Set(ctx, cacheKey, &resp); errCache != nil {
-				s.log.Error("error while caching response", "error", errCache, "job", job)
-			}
+			s.storeCached(ctx, job, cacheKey, &resp)
 		}
 	}
 
-	// process the response if we have a html parser and the resp has no error
-	if resp.Error == nil && s.htmlParser != nil {
-		resp.Document, err = s.htmlParser.Parse(ctx, resp.Body)
-		if err != nil {
-			s.log.Error("error while setting document", "error", err)
+	return s.process(ctx, job, &resp)
+}
+
+// process transcodes and parses the response and calls the job's Process
+func (s *ScrapeMate) process(ctx context.Context, job IJob, resp *Response) (result any, next []IJob, err error) {
+	if resp.Error == nil && s.transcodeCharset {
+		if err = TranscodeToUTF8(resp); err != nil {
+			s.log.Error("error while transcoding response", "error", err)
 
 			return nil, nil, err
 		}
 	}
 
-	result, next, err = job.Process(ctx, &resp)
+	// process the response if we have a parser for it and the resp has no error
+	if resp.Error == nil {
+		if parser := s.parserFor(job, resp); parser != nil {
+			resp.Document, err = parser.Parse(ctx, resp.Body)
+			if err != nil {
+				s.log.Error("error while setting document", "error", err)
+
+				return nil, nil, err
+			}
+		}
+	}
+
+	result, next, err = job.Process(ctx, resp)
 	if err != nil {
 		// TODO shall I retry?
 		s.log.Error("error while processing job", "error", err)
@@ -395,7 +564,45 @@ func (s *ScrapeMate) DoJob(ctx context.Context, job IJob) (result any, next []IJ
 	return result, next, nil
 }
 
-func (s *ScrapeMate) doFetch(ctx context.Context, job IJob) (ans Response) {
+// revalidate sends a conditional request for a cached response.
+// When the server answers 304 Not Modified the cached response is refreshed,
+// stored again and true is returned. Otherwise the fresh response replaces
+// the cached one and it returns false.
+func (s *ScrapeMate) revalidate(ctx context.Context, job IJob, cacheKey string, cached *Response, validators http.Header) bool {
+	fresh := s.doFetch(ContextWithRequestHeaders(ctx, validators), job, true)
+	if fresh.Error != nil || fresh.StatusCode != http.StatusNotModified {
+		*cached = fresh
+
+		return false
+	}
+
+	refreshNotModified(cached, &fresh)
+
+	s.storeCached(ctx, job, cacheKey, cached)
+
+	return true
+}
+
+// storeCached stamps the response with the current time and stores it
+// in the cache unless HTTP cache semantics forbid it.
+func (s *ScrapeMate) storeCached(ctx context.Context, job IJob, cacheKey string, resp *Response) {
+	if s.httpCacheSemantics && isNoStore(resp.Headers) {
+		s.log.Debug("response not cached because of no-store", "job", job)
+
+		return
+	}
+
+	resp.CachedAt = time.Now().UTC()
+	resp.Job = DescribeJob(job)
+
+	if errCache := s.cache.Set(ctx, cacheKey, resp); errCache != nil {
+		s.log.Error("error while caching response", "error", errCache, "job", job)
+	}
+}
+
+// doFetch fetches the job retrying according to its policy.
+// When acceptNotModified is true a 304 response is accepted as is.
+func (s *ScrapeMate) doFetch(ctx context.Context, job IJob, acceptNotModified bool) (ans Response) {
 	var ok bool
 	defer func() {
 		if !ok && ans.Error == nil {
@@ -413,7 +620,7 @@ func (s *ScrapeMate) doFetch(ctx context.Context, job IJob) (ans Response) {
 
 	for {
 		ans = s.httpFetcher.Fetch(ctx, job)
-		ok = job.DoCheckResponse(&ans)
+		ok = (acceptNotModified && ans.Error == nil && ans.StatusCode == http.StatusNotModified) || job.DoCheckResponse(&ans)
 
 		if ok {
 			return ans
@@ -512,7 +719,13 @@ func (s *ScrapeMate) processInitJob(ctx context.Context) error {
 		job, stack = stack[0], stack[1:]
 
 		_, next, err := s.DoJob(ctx, job)
-		if err != nil {
+
+		switch {
+		case s.skipCacheMisses && errors.Is(err, ErrCacheMiss):
+			s.log.Debug("skipping job without cached response", "job", job)
+
+			continue
+		case err != nil:
 			return err
 		}
 
@@ -543,21 +756,51 @@ func (s *ScrapeMate) startWorker(ctx context.Context) {
 			s.log.Info("restarted job provider")
 		case job := <-jobc:
 			ans, next, err := s.DoJob(ctx, job)
-			if err != nil {
+
+			switch {
+			case s.skipCacheMisses && errors.Is(err, ErrCacheMiss):
+				s.log.Debug("skipping job without cached response", "job", job)
+
+				err = nil
+			case err != nil:
 				s.log.Error("error while processing job", "error", err)
 
 				s.pushToFailedJobs(job)
-			} else {
-				if err := s.finishJob(ctx, job, ans, next); err != nil {
+			default:
+				if err = s.finishJob(ctx, job, ans, next); err != nil {
 					s.log.Error("error while finishing job", "error", err)
 
 					s.pushToFailedJobs(job)
 				}
 			}
+
+			s.ackJob(ctx, job, err)
 		}
 	}
 }
 
+// ackJob acknowledges job to an AckingProvider: Ack when jobErr is nil,
+// Nack otherwise. Jobs interrupted by the cancellation of ctx are left
+// to be redelivered.
+func (s *ScrapeMate) ackJob(ctx context.Context, job IJob, jobErr error) {
+	acker, ok := s.jobProvider.(AckingProvider)
+	if !ok || ctx.Err() != nil {
+		return
+	}
+
+	var err error
+
+	if jobErr != nil {
+		err = acker.Nack(ctx, job, jobErr)
+	} else {
+		err = acker.Ack(ctx, job)
+	}
+
+	if err != nil {
+		s.log.Error("error while acknowledging job", "error", err)
+	}
+}
+
 func (s *ScrapeMate) pushToFailedJobs(job IJob) {
 	s.stats.incJobsFailed()
 
@@ -578,7 +821,7 @@ func (s *ScrapeMate) pushToFailedJobs(job IJob) {
 func (s *ScrapeMate) finishJob(ctx context.Context, job IJob, ans any, next []IJob) error {
 	s.stats.incJobsCompleted()
 
-	if err := s.pushJobs(ctx, next); err != nil {
+	if err := s.pushJobs(ContextWithParentJob(ctx, job), next); err != nil {
 		return fmt.Errorf("%w: while pushing jobs", err)
 	}
 
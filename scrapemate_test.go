@@ -3,6 +3,8 @@ package scrapemate_test
 import (
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"syscall"
 	"testing"
 	"time"
@@ -13,6 +15,7 @@ import (
 	"github.com/gosom/kit/logging"
 
 	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/fetchers/nethttp"
 	"github.com/gosom/scrapemate/mock"
 )
 
@@ -222,6 +225,24 @@ func Test_Done_Err(t *testing.T) {
 
 func Test_Start(t *testing.T) {
 	svc := getMockedServices(t)
+	t.Run("skips init job cache misses", func(t *testing.T) {
+		mate, err := scrapemate.New(
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithCache(svc.cache),
+			scrapemate.WithCacheOnlySkipMisses(),
+			scrapemate.WithInitJob(&scrapemate.Job{ID: "init", URL: "http://example.com"}),
+			scrapemate.WithExitBecauseOfInactivity(time.Millisecond*500),
+		)
+		require.NoError(t, err)
+
+		svc.cache.EXPECT().Get(gomock.Any(), gomock.Any()).Return(scrapemate.Response{}, errors.New("not found"))
+		svc.provider.EXPECT().Jobs(gomock.Any()).DoAndReturn(func(context.Context) (<-chan scrapemate.Job, <-chan error) {
+			return make(chan scrapemate.Job), make(chan error)
+		})
+
+		require.NoError(t, mate.Start())
+		require.NoError(t, mate.Close())
+	})
 	t.Run("exits when inactivity", func(t *testing.T) {
 		mate, err := scrapemate.New(
 			scrapemate.WithJobProvider(svc.provider),
@@ -442,6 +463,76 @@ func Test_Start(t *testing.T) {
 			require.Fail(t, "timeout")
 		}
 	})
+	t.Run("acks finished jobs and nacks failed ones", func(t *testing.T) {
+		svc := getMockedServices(t)
+		provider := mock.NewMockAckingProvider(gomock.NewController(t))
+
+		ctx, cancel := context.WithCancelCause(context.Background())
+		defer cancel(errors.New("defer exit"))
+
+		failing := &testJobWithError{Job: scrapemate.Job{URL: "http://example.com/fail"}}
+		succeeding := &testJob{Job: scrapemate.Job{URL: "http://example.com"}}
+
+		jobCh := make(chan scrapemate.IJob, 2)
+		jobCh <- failing
+		jobCh <- succeeding
+
+		provider.EXPECT().Jobs(ctx).Return(jobCh, make(chan error, 1))
+		svc.fetcher.EXPECT().Fetch(gomock.Any(), gomock.Any()).Return(scrapemate.Response{
+			StatusCode: 200,
+			Body:       []byte("test"),
+		}).Times(2)
+
+		acked := make(chan scrapemate.IJob, 2)
+
+		provider.EXPECT().Nack(ctx, failing, gomock.Any()).DoAndReturn(func(_ context.Context, job scrapemate.IJob, err error) error {
+			require.Error(t, err)
+
+			acked <- job
+
+			return nil
+		})
+		provider.EXPECT().Ack(ctx, succeeding).DoAndReturn(func(_ context.Context, job scrapemate.IJob) error {
+			acked <- job
+
+			return nil
+		})
+
+		mate, err := scrapemate.New(
+			scrapemate.WithContext(ctx, cancel),
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(provider),
+		)
+		require.NoError(t, err)
+
+		mateErr := make(chan error, 1)
+
+		go func() {
+			mateErr <- mate.Start()
+		}()
+
+		go func() {
+			for range mate.Results() {
+			}
+		}()
+
+		for range 2 {
+			select {
+			case <-acked:
+			case <-time.After(2 * time.Second):
+				require.FailNow(t, "timeout")
+			}
+		}
+
+		cancel(scrapemate.ErrorExitSignal)
+
+		select {
+		case err := <-mateErr:
+			require.Equal(t, scrapemate.ErrorExitSignal, err)
+		case <-time.After(1 * time.Second):
+			require.Fail(t, "timeout")
+		}
+	})
 	t.Run("happy path with next", func(t *testing.T) {
 		svc := getMockedServices(t)
 
@@ -467,7 +558,11 @@ func Test_Start(t *testing.T) {
 			StatusCode: 200,
 			Body:       []byte("test"),
 		})
-		svc.provider.EXPECT().Push(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, job scrapemate.IJob) error {
+		fromParent := gomock.Cond(func(c context.Context) bool {
+			return c.Err() == nil && scrapemate.ParentJobFromContext(c) == &j
+		})
+
+		svc.provider.EXPECT().Push(fromParent, gomock.Any()).DoAndReturn(func(_ context.Context, job scrapemate.IJob) error {
 			jobCh <- job
 
 			return nil
@@ -630,6 +725,15 @@ func (j *testJob) Process(_ context.Context, _ *scrapemate.Response) (any, []scr
 	return nil, nil, nil
 }
 
+type testJobWithParser struct {
+	scrapemate.Job
+	parser scrapemate.HTMLParser
+}
+
+func (j *testJobWithParser) GetParser() scrapemate.HTMLParser {
+	return j.parser
+}
+
 func Test_DoJob(t *testing.T) {
 	ctx := context.Background()
 	svc := getMockedServices(t)
@@ -854,4 +958,353 @@ func Test_DoJob(t *testing.T) {
 		_, _, err = mate.DoJob(ctx, &job)
 		require.Error(t, err)
 	})
+	t.Run("success+parserByContentType", func(t *testing.T) {
+		jsonParser := mock.NewMockHTMLParser(gomock.NewController(t))
+
+		mate, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithHTMLParser(svc.parser),
+			scrapemate.WithParser("application/json", jsonParser),
+		)
+
+		require.NoError(t, err)
+		require.NotNil(t, mate)
+
+		svc.fetcher.EXPECT().Fetch(gomock.Any(), &job).Return(scrapemate.Response{
+			StatusCode: 200,
+			Headers:    http.Header{"Content-Type": []string{"application/ld+json; charset=utf-8"}},
+			Body:       []byte(`{"a":1}`),
+		})
+		jsonParser.EXPECT().Parse(gomock.Any(), []byte(`{"a":1}`)).Return(map[string]any{"a": 1}, nil)
+
+		_, _, err = mate.DoJob(ctx, &job)
+		require.NoError(t, err)
+
+		svc.fetcher.EXPECT().Fetch(gomock.Any(), &job).Return(scrapemate.Response{
+			StatusCode: 200,
+			Headers:    http.Header{"Content-Type": []string{"text/html"}},
+			Body:       []byte(`<html></html>`),
+		})
+		svc.parser.EXPECT().Parse(gomock.Any(), []byte(`<html></html>`)).Return(nil, nil)
+
+		_, _, err = mate.DoJob(ctx, &job)
+		require.NoError(t, err)
+	})
+	t.Run("success+jobParser", func(t *testing.T) {
+		jobParser := mock.NewMockHTMLParser(gomock.NewController(t))
+
+		mate, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithHTMLParser(svc.parser),
+		)
+
+		require.NoError(t, err)
+		require.NotNil(t, mate)
+
+		job2 := testJobWithParser{Job: job, parser: jobParser}
+
+		svc.fetcher.EXPECT().Fetch(gomock.Any(), &job2).Return(scrapemate.Response{
+			StatusCode: 200,
+			Body:       []byte(`<html></html>`),
+		})
+		jobParser.EXPECT().Parse(gomock.Any(), gomock.Any()).Return(nil, nil)
+
+		_, _, err = mate.DoJob(ctx, &job2)
+		require.NoError(t, err)
+	})
+	t.Run("cache+revalidation+notModified", func(t *testing.T) {
+		mate, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithCache(svc.cache),
+			scrapemate.WithCacheRevalidation(),
+		)
+
+		require.NoError(t, err)
+		require.NotNil(t, mate)
+
+		svc.cache.EXPECT().Get(gomock.Any(), job.GetCacheKey()).Return(scrapemate.Response{
+			StatusCode: 200,
+			Headers:    http.Header{"Etag": []string{`"v1"`}, "Content-Type": []string{"text/html"}},
+			Body:       []byte("<html></html>"),
+		}, nil)
+		svc.fetcher.EXPECT().Fetch(gomock.Any(), &job).DoAndReturn(func(ctx context.Context, _ scrapemate.IJob) scrapemate.Response {
+			require.Equal(t, `"v1"`, scrapemate.RequestHeadersFromContext(ctx).Get("If-None-Match"))
+
+			return scrapemate.Response{
+				StatusCode: 304,
+				Headers:    http.Header{"Etag": []string{`"v1"`}, "Date": []string{"today"}},
+			}
+		})
+		svc.cache.EXPECT().Set(gomock.Any(), job.GetCacheKey(), gomock.Any()).DoAndReturn(func(_ context.Context, _ string, resp *scrapemate.Response) error {
+			require.Equal(t, 200, resp.StatusCode)
+			require.Equal(t, "<html></html>", string(resp.Body))
+			require.Equal(t, "today", resp.Headers.Get("Date"))
+			require.Equal(t, "text/html", resp.Headers.Get("Content-Type"))
+
+			return nil
+		})
+
+		_, _, err = mate.DoJob(ctx, &job)
+		require.NoError(t, err)
+	})
+	t.Run("cache+revalidation+modified", func(t *testing.T) {
+		mate, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithCache(svc.cache),
+			scrapemate.WithCacheRevalidation(),
+		)
+
+		require.NoError(t, err)
+		require.NotNil(t, mate)
+
+		svc.cache.EXPECT().Get(gomock.Any(), job.GetCacheKey()).Return(scrapemate.Response{
+			StatusCode: 200,
+			Headers:    http.Header{"Last-Modified": []string{"Mon, 02 Jan 2006 15:04:05 GMT"}},
+			Body:       []byte("old"),
+		}, nil)
+		svc.fetcher.EXPECT().Fetch(gomock.Any(), &job).Return(scrapemate.Response{
+			StatusCode: 200,
+			Body:       []byte("new"),
+		})
+		svc.cache.EXPECT().Set(gomock.Any(), job.GetCacheKey(), gomock.Any()).DoAndReturn(func(_ context.Context, _ string, resp *scrapemate.Response) error {
+			require.Equal(t, "new", string(resp.Body))
+
+			return nil
+		})
+
+		_, _, err = mate.DoJob(ctx, &job)
+		require.NoError(t, err)
+	})
+	t.Run("cache+maxAge+fresh", func(t *testing.T) {
+		mate, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithCache(svc.cache),
+			scrapemate.WithCacheMaxAge(time.Hour),
+		)
+
+		require.NoError(t, err)
+		require.NotNil(t, mate)
+
+		svc.cache.EXPECT().Get(gomock.Any(), job.GetCacheKey()).Return(scrapemate.Response{
+			StatusCode: 200,
+			Body:       []byte("cached"),
+			CachedAt:   time.Now().Add(-time.Minute),
+		}, nil)
+
+		_, _, err = mate.DoJob(ctx, &job)
+		require.NoError(t, err)
+	})
+	t.Run("cache+maxAge+expired", func(t *testing.T) {
+		mate, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithCache(svc.cache),
+			scrapemate.WithCacheMaxAge(time.Hour),
+		)
+
+		require.NoError(t, err)
+		require.NotNil(t, mate)
+
+		svc.cache.EXPECT().Get(gomock.Any(), job.GetCacheKey()).Return(scrapemate.Response{
+			StatusCode: 200,
+			Body:       []byte("old"),
+			CachedAt:   time.Now().Add(-2 * time.Hour),
+		}, nil)
+		svc.fetcher.EXPECT().Fetch(gomock.Any(), &job).Return(scrapemate.Response{
+			StatusCode: 200,
+			Body:       []byte("new"),
+		})
+		svc.cache.EXPECT().Set(gomock.Any(), job.GetCacheKey(), gomock.Any()).DoAndReturn(func(_ context.Context, _ string, resp *scrapemate.Response) error {
+			require.Equal(t, "new", string(resp.Body))
+			require.WithinDuration(t, time.Now(), resp.CachedAt, time.Minute)
+
+			return nil
+		})
+
+		_, _, err = mate.DoJob(ctx, &job)
+		require.NoError(t, err)
+	})
+	t.Run("cache+jobMaxAge+expired", func(t *testing.T) {
+		mate, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithCache(svc.cache),
+			scrapemate.WithCacheMaxAge(time.Hour),
+		)
+
+		require.NoError(t, err)
+		require.NotNil(t, mate)
+
+		job2 := job
+		job2.CacheMaxAge = time.Second
+
+		svc.cache.EXPECT().Get(gomock.Any(), job2.GetCacheKey()).Return(scrapemate.Response{
+			StatusCode: 200,
+			Body:       []byte("old"),
+			CachedAt:   time.Now().Add(-time.Minute),
+		}, nil)
+		svc.fetcher.EXPECT().Fetch(gomock.Any(), &job2).Return(scrapemate.Response{
+			StatusCode: 200,
+			Body:       []byte("new"),
+		})
+		svc.cache.EXPECT().Set(gomock.Any(), job2.GetCacheKey(), gomock.Any()).Return(nil)
+
+		_, _, err = mate.DoJob(ctx, &job2)
+		require.NoError(t, err)
+	})
+	t.Run("cache+httpSemantics", func(t *testing.T) {
+		mate, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithCache(svc.cache),
+			scrapemate.WithCacheMaxAge(time.Hour),
+			scrapemate.WithHTTPCacheSemantics(),
+		)
+
+		require.NoError(t, err)
+		require.NotNil(t, mate)
+
+		// max-age=30 overrides the configured hour
+		svc.cache.EXPECT().Get(gomock.Any(), job.GetCacheKey()).Return(scrapemate.Response{
+			StatusCode: 200,
+			Headers:    http.Header{"Cache-Control": []string{"public, max-age=30"}},
+			Body:       []byte("old"),
+			CachedAt:   time.Now().Add(-time.Minute),
+		}, nil)
+		svc.fetcher.EXPECT().Fetch(gomock.Any(), &job).Return(scrapemate.Response{
+			StatusCode: 200,
+			Headers:    http.Header{"Cache-Control": []string{"no-store"}},
+			Body:       []byte("new"),
+		})
+
+		// no-store responses are not cached
+		_, _, err = mate.DoJob(ctx, &job)
+		require.NoError(t, err)
+
+		svc.cache.EXPECT().Get(gomock.Any(), job.GetCacheKey()).Return(scrapemate.Response{
+			StatusCode: 200,
+			Headers: http.Header{
+				"Date":    []string{time.Now().UTC().Format(http.TimeFormat)},
+				"Expires": []string{time.Now().UTC().Add(2 * time.Hour).Format(http.TimeFormat)},
+			},
+			Body:     []byte("cached"),
+			CachedAt: time.Now().Add(-90 * time.Minute),
+		}, nil)
+
+		_, _, err = mate.DoJob(ctx, &job)
+		require.NoError(t, err)
+	})
+	t.Run("cacheOnly+noCache", func(t *testing.T) {
+		_, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithCacheOnly(),
+		)
+		require.ErrorIs(t, err, scrapemate.ErrorNoCacher)
+	})
+	t.Run("cacheOnly", func(t *testing.T) {
+		mate, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithCache(svc.cache),
+			scrapemate.WithCacheMaxAge(time.Hour),
+			scrapemate.WithCacheOnly(),
+		)
+
+		require.NoError(t, err)
+		require.NotNil(t, mate)
+
+		// expired responses are used and nothing is fetched
+		svc.cache.EXPECT().Get(gomock.Any(), job.GetCacheKey()).Return(scrapemate.Response{
+			StatusCode: 200,
+			Body:       []byte("old"),
+			CachedAt:   time.Now().Add(-2 * time.Hour),
+		}, nil)
+
+		_, _, err = mate.DoJob(ctx, &job)
+		require.NoError(t, err)
+
+		svc.cache.EXPECT().Get(gomock.Any(), job.GetCacheKey()).Return(scrapemate.Response{}, errors.New("not found"))
+
+		_, _, err = mate.DoJob(ctx, &job)
+		require.ErrorIs(t, err, scrapemate.ErrCacheMiss)
+	})
+	t.Run("cacheOnly+noFetcher", func(t *testing.T) {
+		mate, err := scrapemate.New(
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithCache(svc.cache),
+			scrapemate.WithCacheOnly(),
+		)
+		require.NoError(t, err)
+
+		svc.cache.EXPECT().Get(gomock.Any(), job.GetCacheKey()).Return(scrapemate.Response{StatusCode: 200}, nil)
+
+		_, _, err = mate.DoJob(ctx, &job)
+		require.NoError(t, err)
+		require.NoError(t, mate.Close())
+	})
+	t.Run("success+charsetTranscoding", func(t *testing.T) {
+		mate, err := scrapemate.New(
+			scrapemate.WithHTTPFetcher(svc.fetcher),
+			scrapemate.WithJobProvider(svc.provider),
+			scrapemate.WithHTMLParser(svc.parser),
+			scrapemate.WithCharsetTranscoding(),
+		)
+
+		require.NoError(t, err)
+		require.NotNil(t, mate)
+
+		svc.fetcher.EXPECT().Fetch(gomock.Any(), &job).Return(scrapemate.Response{
+			StatusCode: 200,
+			Headers:    http.Header{"Content-Type": []string{"text/html; charset=iso-8859-7"}},
+			Body:       []byte{'<', 'p', '>', 0xe1, 0xe2, 0xe3, '<', '/', 'p', '>'},
+		})
+		svc.parser.EXPECT().Parse(gomock.Any(), []byte("<p>αβγ</p>")).Return(nil, nil)
+
+		_, _, err = mate.DoJob(ctx, &job)
+		require.NoError(t, err)
+	})
+}
+
+func Test_RevalidationWithResponseLimits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, `"v1"`, r.Header.Get("If-None-Match"))
+
+		w.Header().Set("Etag", `"v1"`)
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	t.Cleanup(srv.Close)
+
+	svc := getMockedServices(t)
+
+	mate, err := scrapemate.New(
+		scrapemate.WithHTTPFetcher(nethttp.New(&http.Client{}, nethttp.WithResponseLimits(scrapemate.ResponseLimits{
+			AllowedContentTypes: []string{"text/html"},
+		}))),
+		scrapemate.WithJobProvider(svc.provider),
+		scrapemate.WithCache(svc.cache),
+		scrapemate.WithCacheRevalidation(),
+	)
+	require.NoError(t, err)
+
+	job := scrapemate.Job{Method: http.MethodGet, URL: srv.URL}
+
+	svc.cache.EXPECT().Get(gomock.Any(), job.GetCacheKey()).Return(scrapemate.Response{
+		StatusCode: 200,
+		Headers:    http.Header{"Etag": []string{`"v1"`}, "Content-Type": []string{"text/html"}},
+		Body:       []byte("<html></html>"),
+	}, nil)
+	svc.cache.EXPECT().Set(gomock.Any(), job.GetCacheKey(), gomock.Any()).DoAndReturn(func(_ context.Context, _ string, resp *scrapemate.Response) error {
+		require.Equal(t, "<html></html>", string(resp.Body))
+
+		return nil
+	})
+
+	_, _, err = mate.DoJob(context.Background(), &job)
+	require.NoError(t, err)
 }
@@ -2,6 +2,7 @@ package scrapemateapp
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -35,6 +36,20 @@ type Config struct {
 	Proxies                  []string
 	BrowserReuseLimit        int
 	PageReuseLimit           int
+	ResponseLimits           scrapemate.ResponseLimits
+	TranscodeCharset         bool
+	ParseByContentType       bool
+	CacheRevalidate          bool
+	CacheMaxAge              time.Duration `validate:"omitempty,gt=0"`
+	HTTPCacheSemantics       bool
+	CacheOnly                bool
+	CacheOnlySkipMisses      bool
+	CacheMaxSize             int64 `validate:"gte=0"`
+	WARCDir                  string
+	CacheMemoryTier          bool
+	CacheMemoryEntries       int   `validate:"gte=0"`
+	CacheMemoryBytes         int64 `validate:"gte=0"`
+	Schedules                []Schedule
 }
 
 func (o *Config) validate() error {
@@ -127,6 +142,116 @@ func WithCache(cacheType, cachePath string) func(*Config) error {
 	}
 }
 
+// WithCacheRevalidation revalidates cached responses with conditional
+// requests (ETag / Last-Modified) instead of serving them as they are.
+func WithCacheRevalidation() func(*Config) error {
+	return func(o *Config) error {
+		o.CacheRevalidate = true
+
+		return nil
+	}
+}
+
+// WithCacheMaxAge treats cached responses older than maxAge as expired
+func WithCacheMaxAge(maxAge time.Duration) func(*Config) error {
+	return func(o *Config) error {
+		o.CacheMaxAge = maxAge
+
+		return o.validate()
+	}
+}
+
+// WithHTTPCacheSemantics honors the Cache-Control and Expires headers
+// of the responses when caching them.
+func WithHTTPCacheSemantics() func(*Config) error {
+	return func(o *Config) error {
+		o.HTTPCacheSemantics = true
+
+		return nil
+	}
+}
+
+// WithCacheOnly never fetches: jobs are served from the cache and
+// the ones without a cached response fail with scrapemate.ErrCacheMiss.
+// It requires WithCache. No fetcher is created, so the fetcher options
+// are ignored.
+func WithCacheOnly() func(*Config) error {
+	return func(o *Config) error {
+		o.CacheOnly = true
+
+		return nil
+	}
+}
+
+// WithCacheOnlySkipMisses is like WithCacheOnly but jobs without a
+// cached response are skipped.
+func WithCacheOnlySkipMisses() func(*Config) error {
+	return func(o *Config) error {
+		o.CacheOnly = true
+		o.CacheOnlySkipMisses = true
+
+		return nil
+	}
+}
+
+// WithCacheMaxSize bounds the size of a file cache on disk evicting the
+// least recently used entries
+func WithCacheMaxSize(maxBytes int64) func(*Config) error {
+	return func(o *Config) error {
+		o.CacheMaxSize = maxBytes
+
+		return o.validate()
+	}
+}
+
+// WithCacheMemoryTier keeps the most recently used cached responses in
+// memory in front of the cache set with WithCache. Zero limits use the
+// defaults of tieredcache.
+func WithCacheMemoryTier(maxEntries int, maxBytes int64) func(*Config) error {
+	return func(o *Config) error {
+		o.CacheMemoryTier = true
+		o.CacheMemoryEntries = maxEntries
+		o.CacheMemoryBytes = maxBytes
+
+		return o.validate()
+	}
+}
+
+// WithSchedule enqueues the jobs of schedule periodically while the app
+// runs. Runs of a schedule never overlap: a run due while the previous
+// one still has unfinished jobs is skipped.
+func WithSchedule(schedule Schedule) func(*Config) error {
+	return func(o *Config) error {
+		if _, err := schedule.parse(); err != nil {
+			return err
+		}
+
+		for i := range o.Schedules {
+			if o.Schedules[i].Name == schedule.Name {
+				return fmt.Errorf("duplicate schedule %s", schedule.Name)
+			}
+		}
+
+		o.Schedules = append(o.Schedules, schedule)
+
+		return nil
+	}
+}
+
+// WithWARC archives every fetched response to rotating .warc.gz files
+// in dir
+func WithWARC(dir string) func(*Config) error {
+	return func(o *Config) error {
+		if dir == "" {
+			return errors.New("warc dir cannot be empty")
+		}
+
+		o.WARCDir = dir
+
+		return nil
+	}
+}
+
 func WithJS(opts ...func(*jsOptions)) func(*Config) error {
 	return func(o *Config) error {
 		o.UseJS = true
@@ -176,6 +301,40 @@ func WithProxies(proxies []string) func(*Config) error {
 	}
 }
 
+// WithResponseLimits sets the global response limits passed to the fetcher.
+// Jobs may override them by implementing scrapemate.ResponseLimiter.
+func WithResponseLimits(limits scrapemate.ResponseLimits) func(*Config) error {
+	return func(o *Config) error {
+		if limits.MaxBodySize < 0 {
+			return errors.New("max body size cannot be negative")
+		}
+
+		o.ResponseLimits = limits
+
+		return nil
+	}
+}
+
+// WithCharsetTranscoding converts response bodies to UTF-8 before parsing.
+func WithCharsetTranscoding() func(*Config) error {
+	return func(o *Config) error {
+		o.TranscodeCharset = true
+
+		return nil
+	}
+}
+
+// WithContentTypeParsers dispatches responses to a parser based on their
+// Content-Type: JSON responses are decoded by jsonparser, XML responses by
+// xmlparser and everything else goes to goquery.
+func WithContentTypeParsers() func(*Config) error {
+	return func(o *Config) error {
+		o.ParseByContentType = true
+
+		return nil
+	}
+}
+
 func Headfull() func(*jsOptions) {
 	return func(o *jsOptions) {
 		o.Headfull = true
@@ -0,0 +1,401 @@
+package scrapemateapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/gosom/scrapemate"
+)
+
+var _ scrapemate.AckingProvider = (*scheduler)(nil)
+
+// defaultScheduleHistory is the number of runs kept per schedule
+const defaultScheduleHistory = 100
+
+// Schedule periodically enqueues a set of jobs in a long-running app
+type Schedule struct {
+	// Name identifies the schedule in the run history
+	Name string
+	// Cron is a standard cron expression, like "0 * * * *", or a
+	// descriptor like "@daily". Times are local unless the expression
+	// starts with CRON_TZ=.
+	Cron string
+	// Every runs the schedule at a fixed interval when Cron is empty
+	Every time.Duration
+	// Jobs returns the jobs of a run. It's called on every run so runs
+	// don't share job instances. Jobs are told apart by ID, so they need
+	// one; next jobs without an ID are not counted in the run.
+	Jobs func(ctx context.Context) ([]scrapemate.IJob, error)
+	// RunOnStart runs the schedule as soon as the app starts too
+	RunOnStart bool
+	// HistorySize is the number of runs kept, 100 by default
+	HistorySize int
+}
+
+// ScheduleRun is a run of a Schedule
+type ScheduleRun struct {
+	Start time.Time
+	// End is when the last job of the run, next jobs included, was
+	// finished. It's zero while the run is in progress.
+	End time.Time
+	// Jobs is the number of jobs of the run, next jobs included
+	Jobs int
+	// Failed is the number of failed jobs of the run
+	Failed int
+	// Skipped is true when the previous run was still in progress
+	Skipped bool
+	// Err is the error that stopped enqueuing the jobs of the run
+	Err error
+}
+
+// nextFunc returns the next activation time after t
+type nextFunc func(t time.Time) time.Time
+
+func (s *Schedule) parse() (nextFunc, error) {
+	switch {
+	case s.Name == "":
+		return nil, errors.New("schedule name cannot be empty")
+	case s.Jobs == nil:
+		return nil, fmt.Errorf("schedule %s has no jobs", s.Name)
+	case s.Cron != "" && s.Every != 0:
+		return nil, fmt.Errorf("schedule %s cannot have both cron and interval", s.Name)
+	case s.Cron != "":
+		sched, err := cron.ParseStandard(s.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: invalid cron expression: %w", s.Name, err)
+		}
+
+		return sched.Next, nil
+	case s.Every > 0:
+		return func(t time.Time) time.Time { return t.Add(s.Every) }, nil
+	default:
+		return nil, fmt.Errorf("schedule %s needs a cron expression or a positive interval", s.Name)
+	}
+}
+
+type scheduleState struct {
+	Schedule
+	next    nextFunc
+	current *runState
+	history []*runState
+}
+
+type runState struct {
+	ScheduleRun
+	schedule *scheduleState
+	// pending is the number of unfinished jobs, plus one while enqueuing
+	pending int
+}
+
+// scheduler runs the schedules of an app. As the app's provider it
+// wraps the configured one to tell when the jobs of a run, and the next
+// jobs they return, are finished.
+type scheduler struct {
+	provider  scrapemate.JobProvider
+	schedules []*scheduleState
+
+	mu sync.Mutex
+	// jobs maps the IDs of the unfinished jobs to their runs, once per
+	// push of a job with that ID. Jobs are told apart by ID since
+	// persistent providers hand out copies of the pushed jobs.
+	jobs map[string][]*runState
+}
+
+func newScheduler(schedules []Schedule) (*scheduler, error) {
+	s := scheduler{jobs: make(map[string][]*runState)}
+
+	for i := range schedules {
+		next, err := schedules[i].parse()
+		if err != nil {
+			return nil, err
+		}
+
+		st := scheduleState{Schedule: schedules[i], next: next}
+		if st.HistorySize <= 0 {
+			st.HistorySize = defaultScheduleHistory
+		}
+
+		s.schedules = append(s.schedules, &st)
+	}
+
+	return &s, nil
+}
+
+// run runs the schedules until ctx is done
+func (s *scheduler) run(ctx context.Context) error {
+	var wg sync.WaitGroup
+
+	for _, st := range s.schedules {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			s.loop(ctx, st)
+		}()
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+func (s *scheduler) loop(ctx context.Context, st *scheduleState) {
+	if st.RunOnStart {
+		s.trigger(ctx, st)
+	}
+
+	for {
+		timer := time.NewTimer(time.Until(st.next(time.Now())))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return
+		case <-timer.C:
+			s.trigger(ctx, st)
+		}
+	}
+}
+
+// trigger starts a run of st unless the previous one is in progress
+func (s *scheduler) trigger(ctx context.Context, st *scheduleState) {
+	s.mu.Lock()
+
+	run := &runState{
+		ScheduleRun: ScheduleRun{Start: time.Now()},
+		schedule:    st,
+		pending:     1,
+	}
+
+	if st.current != nil {
+		run.Skipped = true
+		run.End = run.Start
+		s.record(run)
+		s.mu.Unlock()
+
+		scrapemate.GetLoggerFromContext(ctx).Info("skipping run, the previous one is in progress", "schedule", st.Name)
+
+		return
+	}
+
+	st.current = run
+	s.record(run)
+	s.mu.Unlock()
+
+	err := s.enqueue(ctx, run)
+	if err != nil {
+		scrapemate.GetLoggerFromContext(ctx).Error("cannot enqueue scheduled jobs", "schedule", st.Name, "error", err)
+	}
+
+	s.mu.Lock()
+	run.Err = err
+	s.release(run, false)
+	s.mu.Unlock()
+}
+
+func (s *scheduler) enqueue(ctx context.Context, run *runState) error {
+	jobs, err := run.schedule.Jobs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if job.GetID() == "" {
+			return fmt.Errorf("schedule %s: job %s has no ID", run.schedule.Name, job.GetURL())
+		}
+	}
+
+	for _, job := range jobs {
+		s.mu.Lock()
+		s.track(job, run)
+		s.mu.Unlock()
+
+		if err := s.provider.Push(ctx, job); err != nil {
+			s.mu.Lock()
+			s.untrack(job, run)
+			s.mu.Unlock()
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// record appends run to the history of its schedule.
+// It must be called with mu held.
+func (s *scheduler) record(run *runState) {
+	st := run.schedule
+
+	st.history = append(st.history, run)
+	if over := len(st.history) - st.HistorySize; over > 0 {
+		st.history = append(st.history[:0], st.history[over:]...)
+	}
+}
+
+// track adds job to run. It must be called with mu held.
+func (s *scheduler) track(job scrapemate.IJob, run *runState) {
+	id := job.GetID()
+
+	s.jobs[id] = append(s.jobs[id], run)
+	run.pending++
+	run.Jobs++
+}
+
+// untrack removes a job of run that could not be pushed.
+// It must be called with mu held.
+func (s *scheduler) untrack(job scrapemate.IJob, run *runState) {
+	id := job.GetID()
+
+	runs := s.jobs[id]
+
+	// the last push of run with the ID is the one that failed
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i] == run {
+			s.drop(id, i)
+
+			run.Jobs--
+			s.release(run, false)
+
+			return
+		}
+	}
+}
+
+// drop removes the i-th push of the ID id. It must be called with mu held.
+func (s *scheduler) drop(id string, i int) {
+	runs := slices.Delete(s.jobs[id], i, i+1)
+	if len(runs) == 0 {
+		delete(s.jobs, id)
+	} else {
+		s.jobs[id] = runs
+	}
+}
+
+// runOf returns the run of the job with the ID id, the one of its first
+// push if it was pushed several times. It must be called with mu held.
+func (s *scheduler) runOf(id string) (*runState, bool) {
+	runs := s.jobs[id]
+	if len(runs) == 0 {
+		return nil, false
+	}
+
+	return runs[0], true
+}
+
+// release marks a job of run finished and ends the run when it was the
+// last one. It must be called with mu held.
+func (s *scheduler) release(run *runState, failed bool) {
+	if failed {
+		run.Failed++
+	}
+
+	run.pending--
+	if run.pending > 0 {
+		return
+	}
+
+	run.End = time.Now()
+
+	if run.schedule.current == run {
+		run.schedule.current = nil
+	}
+}
+
+// finish releases the run of job, if any
+func (s *scheduler) finish(job scrapemate.IJob, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := job.GetID()
+
+	if run, ok := s.runOf(id); ok {
+		s.drop(id, 0)
+		s.release(run, failed)
+	}
+}
+
+// runs returns the history of the schedule name, oldest first
+func (s *scheduler) runs(name string) []ScheduleRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, st := range s.schedules {
+		if st.Name != name {
+			continue
+		}
+
+		ans := make([]ScheduleRun, 0, len(st.history))
+		for _, run := range st.history {
+			ans = append(ans, run.ScheduleRun)
+		}
+
+		return ans
+	}
+
+	return nil
+}
+
+// Jobs returns the jobs of the wrapped provider
+//
+//nolint:gocritic // we need to return a read only channel
+func (s *scheduler) Jobs(ctx context.Context) (<-chan scrapemate.IJob, <-chan error) {
+	return s.provider.Jobs(ctx)
+}
+
+// Push pushes job to the wrapped provider. Jobs with an ID returned by a
+// job of a run join the run.
+func (s *scheduler) Push(ctx context.Context, job scrapemate.IJob) error {
+	var tracked *runState
+
+	if parent := scrapemate.ParentJobFromContext(ctx); parent != nil && job.GetID() != "" {
+		s.mu.Lock()
+
+		if run, ok := s.runOf(parent.GetID()); ok {
+			s.track(job, run)
+
+			tracked = run
+		}
+
+		s.mu.Unlock()
+	}
+
+	err := s.provider.Push(ctx, job)
+	if err != nil && tracked != nil {
+		s.mu.Lock()
+		s.untrack(job, tracked)
+		s.mu.Unlock()
+	}
+
+	return err
+}
+
+// Ack finishes job and acknowledges it to the wrapped provider
+func (s *scheduler) Ack(ctx context.Context, job scrapemate.IJob) error {
+	s.finish(job, false)
+
+	if acker, ok := s.provider.(scrapemate.AckingProvider); ok {
+		return acker.Ack(ctx, job)
+	}
+
+	return nil
+}
+
+// Nack finishes job as failed and reports it to the wrapped provider
+func (s *scheduler) Nack(ctx context.Context, job scrapemate.IJob, err error) error {
+	s.finish(job, true)
+
+	if acker, ok := s.provider.(scrapemate.AckingProvider); ok {
+		return acker.Nack(ctx, job, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,277 @@
+package scrapemateapp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/providers/heapprovider"
+)
+
+func newTestScheduler(t *testing.T, schedule Schedule) *scheduler {
+	t.Helper()
+
+	s, err := newScheduler([]Schedule{schedule})
+	require.NoError(t, err)
+
+	s.provider, err = heapprovider.New()
+	require.NoError(t, err)
+
+	return s
+}
+
+func receiveJob(t *testing.T, jobs <-chan scrapemate.IJob) scrapemate.IJob {
+	t.Helper()
+
+	select {
+	case job := <-jobs:
+		return job
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no job received")
+	}
+
+	return nil
+}
+
+// copyingProvider hands out copies of the pushed jobs like persistent
+// providers do
+type copyingProvider struct {
+	*heapprovider.Provider
+}
+
+//nolint:gocritic // we need to return a read only channel
+func (p copyingProvider) Jobs(ctx context.Context) (<-chan scrapemate.IJob, <-chan error) {
+	in, errc := p.Provider.Jobs(ctx)
+	out := make(chan scrapemate.IJob)
+
+	go func() {
+		for job := range in {
+			//nolint:errcheck // only *scrapemate.Job values are pushed
+			cp := *job.(*scrapemate.Job)
+
+			select {
+			case out <- &cp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, errc
+}
+
+func Test_scheduler(t *testing.T) {
+	seeds := func(context.Context) ([]scrapemate.IJob, error) {
+		return []scrapemate.IJob{&scrapemate.Job{ID: "seed", URL: "http://example.com"}}, nil
+	}
+
+	t.Run("run ends when its jobs and their next jobs are finished", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		s := newTestScheduler(t, Schedule{Name: "prices", Every: time.Hour, Jobs: seeds})
+		jobs, _ := s.Jobs(ctx)
+
+		s.trigger(ctx, s.schedules[0])
+
+		seed := receiveJob(t, jobs)
+		next := &scrapemate.Job{ID: "next", URL: "http://example.com/next"}
+		require.NoError(t, s.Push(scrapemate.ContextWithParentJob(ctx, seed), next))
+		require.NoError(t, s.Ack(ctx, seed))
+
+		runs := s.runs("prices")
+		require.Len(t, runs, 1)
+		require.True(t, runs[0].End.IsZero())
+
+		require.NoError(t, s.Nack(ctx, receiveJob(t, jobs), errors.New("failed")))
+
+		runs = s.runs("prices")
+		require.Len(t, runs, 1)
+		require.False(t, runs[0].End.IsZero())
+		require.Equal(t, 2, runs[0].Jobs)
+		require.Equal(t, 1, runs[0].Failed)
+		require.False(t, runs[0].Skipped)
+	})
+
+	t.Run("tracks copies of the jobs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		s := newTestScheduler(t, Schedule{Name: "prices", Every: time.Hour, Jobs: seeds})
+		s.provider = copyingProvider{Provider: s.provider.(*heapprovider.Provider)} //nolint:errcheck // set by newTestScheduler
+		jobs, _ := s.Jobs(ctx)
+
+		s.trigger(ctx, s.schedules[0])
+
+		seed := receiveJob(t, jobs)
+		require.NoError(t, s.Push(scrapemate.ContextWithParentJob(ctx, seed), &scrapemate.Job{ID: "next"}))
+		require.NoError(t, s.Ack(ctx, seed))
+		require.NoError(t, s.Ack(ctx, receiveJob(t, jobs)))
+
+		runs := s.runs("prices")
+		require.Len(t, runs, 1)
+		require.False(t, runs[0].End.IsZero())
+		require.Equal(t, 2, runs[0].Jobs)
+	})
+
+	t.Run("duplicate IDs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		twice := func(context.Context) ([]scrapemate.IJob, error) {
+			return []scrapemate.IJob{&scrapemate.Job{ID: "seed"}, &scrapemate.Job{ID: "seed"}}, nil
+		}
+
+		s, err := newScheduler([]Schedule{
+			{Name: "prices", Every: time.Hour, Jobs: twice},
+			{Name: "stock", Every: time.Hour, Jobs: seeds},
+		})
+		require.NoError(t, err)
+
+		s.provider, err = heapprovider.New()
+		require.NoError(t, err)
+
+		jobs, _ := s.Jobs(ctx)
+
+		s.trigger(ctx, s.schedules[0])
+		s.trigger(ctx, s.schedules[1])
+
+		for range 3 {
+			require.NoError(t, s.Ack(ctx, receiveJob(t, jobs)))
+		}
+
+		s.trigger(ctx, s.schedules[0])
+		s.trigger(ctx, s.schedules[1])
+
+		for _, name := range []string{"prices", "stock"} {
+			runs := s.runs(name)
+			require.Len(t, runs, 2)
+			require.False(t, runs[0].End.IsZero())
+			require.False(t, runs[1].Skipped)
+		}
+	})
+
+	t.Run("rejects jobs without an ID", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		s := newTestScheduler(t, Schedule{
+			Name:  "prices",
+			Every: time.Hour,
+			Jobs: func(context.Context) ([]scrapemate.IJob, error) {
+				return []scrapemate.IJob{&scrapemate.Job{ID: "1"}, &scrapemate.Job{URL: "http://example.com"}}, nil
+			},
+		})
+
+		s.trigger(ctx, s.schedules[0])
+
+		runs := s.runs("prices")
+		require.Len(t, runs, 1)
+		require.ErrorContains(t, runs[0].Err, "has no ID")
+		require.False(t, runs[0].End.IsZero())
+		require.Zero(t, runs[0].Jobs)
+	})
+
+	t.Run("skips overlapping runs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		s := newTestScheduler(t, Schedule{Name: "prices", Every: time.Hour, Jobs: seeds})
+		jobs, _ := s.Jobs(ctx)
+
+		s.trigger(ctx, s.schedules[0])
+		s.trigger(ctx, s.schedules[0])
+
+		require.NoError(t, s.Ack(ctx, receiveJob(t, jobs)))
+
+		s.trigger(ctx, s.schedules[0])
+
+		runs := s.runs("prices")
+		require.Len(t, runs, 3)
+		require.False(t, runs[0].Skipped)
+		require.True(t, runs[1].Skipped)
+		require.False(t, runs[2].Skipped)
+		require.True(t, runs[2].End.IsZero())
+	})
+
+	t.Run("runs on intervals", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		s := newTestScheduler(t, Schedule{
+			Name:        "prices",
+			Every:       10 * time.Millisecond,
+			Jobs:        func(context.Context) ([]scrapemate.IJob, error) { return nil, nil },
+			RunOnStart:  true,
+			HistorySize: 3,
+		})
+
+		done := make(chan error, 1)
+
+		go func() {
+			done <- s.run(ctx)
+		}()
+
+		require.Eventually(t, func() bool {
+			return len(s.runs("prices")) == 3
+		}, 5*time.Second, time.Millisecond)
+
+		cancel()
+		require.NoError(t, <-done)
+
+		for _, run := range s.runs("prices") {
+			require.False(t, run.Skipped)
+			require.False(t, run.End.IsZero())
+			require.Zero(t, run.Jobs)
+		}
+	})
+
+	t.Run("records enqueue errors", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		s := newTestScheduler(t, Schedule{
+			Name:  "prices",
+			Every: time.Hour,
+			Jobs:  func(context.Context) ([]scrapemate.IJob, error) { return nil, errors.New("no seeds") },
+		})
+
+		s.trigger(ctx, s.schedules[0])
+
+		runs := s.runs("prices")
+		require.Len(t, runs, 1)
+		require.EqualError(t, runs[0].Err, "no seeds")
+		require.False(t, runs[0].End.IsZero())
+		require.Nil(t, s.runs("unknown"))
+	})
+}
+
+func Test_WithSchedule(t *testing.T) {
+	jobs := func(context.Context) ([]scrapemate.IJob, error) { return nil, nil }
+
+	for name, schedule := range map[string]Schedule{
+		"no name":         {Every: time.Hour, Jobs: jobs},
+		"no jobs":         {Name: "a", Every: time.Hour},
+		"no period":       {Name: "a", Jobs: jobs},
+		"cron and every":  {Name: "a", Cron: "@daily", Every: time.Hour, Jobs: jobs},
+		"invalid cron":    {Name: "a", Cron: "61 * * * *", Jobs: jobs},
+		"negative period": {Name: "a", Every: -time.Hour, Jobs: jobs},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.Error(t, WithSchedule(schedule)(&Config{}))
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		var cfg Config
+
+		require.NoError(t, WithSchedule(Schedule{Name: "daily", Cron: "0 6 * * *", Jobs: jobs})(&cfg))
+		require.NoError(t, WithSchedule(Schedule{Name: "hourly", Every: time.Hour, Jobs: jobs})(&cfg))
+		require.Error(t, WithSchedule(Schedule{Name: "hourly", Every: time.Hour, Jobs: jobs})(&cfg))
+		require.Len(t, cfg.Schedules, 2)
+	})
+}
@@ -12,11 +12,15 @@ import (
 
 	"github.com/gosom/scrapemate/adapters/cache/filecache"
 	"github.com/gosom/scrapemate/adapters/cache/leveldbcache"
+	"github.com/gosom/scrapemate/adapters/cache/tieredcache"
 	fetcher "github.com/gosom/scrapemate/adapters/fetchers/nethttp"
 	"github.com/gosom/scrapemate/adapters/fetchers/stealth"
 	parser "github.com/gosom/scrapemate/adapters/parsers/goqueryparser"
+	"github.com/gosom/scrapemate/adapters/parsers/jsonparser"
+	"github.com/gosom/scrapemate/adapters/parsers/xmlparser"
 	memprovider "github.com/gosom/scrapemate/adapters/providers/memory"
 	"github.com/gosom/scrapemate/adapters/proxy"
+	"github.com/gosom/scrapemate/adapters/warc"
 )
 
 type ScrapemateApp struct {
@@ -25,8 +29,9 @@ type ScrapemateApp struct {
 	ctx    context.Context
 	cancel context.CancelCauseFunc
 
-	provider scrapemate.JobProvider
-	cacher   scrapemate.Cacher
+	provider  scrapemate.JobProvider
+	cacher    scrapemate.Cacher
+	scheduler *scheduler
 }
 
 // NewScrapemateApp creates a new ScrapemateApp.
@@ -35,6 +40,14 @@ func NewScrapeMateApp(cfg *Config) (*ScrapemateApp, error) {
 		cfg: cfg,
 	}
 
+	if len(cfg.Schedules) > 0 {
+		var err error
+
+		if app.scheduler, err = newScheduler(cfg.Schedules); err != nil {
+			return nil, err
+		}
+	}
+
 	return &app, nil
 }
 
@@ -70,6 +83,12 @@ func (app *ScrapemateApp) Start(ctx context.Context, seedJobs ...scrapemate.IJob
 		return mate.Start()
 	})
 
+	if app.scheduler != nil {
+		g.Go(func() error {
+			return app.scheduler.run(ctx)
+		})
+	}
+
 	g.Go(func() error {
 		for i := range seedJobs {
 			if err := app.provider.Push(ctx, seedJobs[i]); err != nil {
@@ -83,6 +102,59 @@ func (app *ScrapemateApp) Start(ctx context.Context, seedJobs ...scrapemate.IJob
 	return g.Wait()
 }
 
+// Reprocess reruns Process for every response in the cache without
+// fetching anything. Jobs are rebuilt from the descriptors stored with the
+// cached responses using registry and the results go to the writers.
+// A cache must be configured; no fetcher, browser or WARC writer is
+// started.
+func (app *ScrapemateApp) Reprocess(ctx context.Context, registry *scrapemate.JobRegistry) error {
+	if app.cfg.CacheType == "" {
+		return errors.New("reprocess needs a cache")
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	defer cancel(errors.New("closing app"))
+
+	mate, err := app.getReprocessMate(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer app.Close()
+	defer mate.Close()
+
+	for i := range app.cfg.Writers {
+		writer := app.cfg.Writers[i]
+
+		g.Go(func() error {
+			if err := writer.Run(ctx, mate.Results()); err != nil {
+				cancel(err)
+				return err
+			}
+
+			return nil
+		})
+	}
+
+	g.Go(func() error {
+		return mate.Reprocess(registry)
+	})
+
+	return g.Wait()
+}
+
+// ScheduleRuns returns the recent runs of the schedule name, oldest first.
+// It returns nil for unknown schedules.
+func (app *ScrapemateApp) ScheduleRuns(name string) []ScheduleRun {
+	if app.scheduler == nil {
+		return nil
+	}
+
+	return app.scheduler.runs(name)
+}
+
 // Close closes the app.
 func (app *ScrapemateApp) Close() error {
 	if app.cacher != nil {
@@ -100,36 +172,131 @@ func (app *ScrapemateApp) getMate(ctx context.Context) (*scrapemate.ScrapeMate,
 		return nil, err
 	}
 
-	fetcherInstance, err := app.getFetcher()
-	if err != nil {
-		return nil, err
-	}
+	// the scheduler wraps the provider of the first call only, it's never
+	// wrapped twice
+	if app.scheduler != nil {
+		if app.scheduler.provider == nil {
+			app.scheduler.provider = app.provider
+		}
 
-	app.cacher, err = app.getCacher()
-	if err != nil {
-		return nil, err
+		app.provider = app.scheduler
 	}
 
 	params := []func(*scrapemate.ScrapeMate) error{
 		scrapemate.WithContext(ctx, app.cancel),
 		scrapemate.WithJobProvider(app.provider),
-		scrapemate.WithHTTPFetcher(fetcherInstance),
 		scrapemate.WithHTMLParser(parser.New()),
 		scrapemate.WithConcurrency(app.cfg.Concurrency),
 		scrapemate.WithExitBecauseOfInactivity(app.cfg.ExitOnInactivityDuration),
 	}
 
+	// nothing is fetched in cache only mode, so no fetcher or browser is
+	// started
+	if !app.cfg.CacheOnly {
+		fetcherInstance, err := app.getFetcher()
+		if err != nil {
+			return nil, err
+		}
+
+		if app.cfg.WARCDir != "" {
+			w, err := warc.NewWriter(app.cfg.WARCDir)
+			if err != nil {
+				_ = fetcherInstance.Close()
+
+				return nil, err
+			}
+
+			fetcherInstance = warc.NewFetcher(fetcherInstance, w)
+		}
+
+		params = append(params, scrapemate.WithHTTPFetcher(fetcherInstance))
+	}
+
+	app.cacher, err = app.getCacher()
+	if err != nil {
+		return nil, err
+	}
+
 	if app.cacher != nil {
 		params = append(params, scrapemate.WithCache(app.cacher))
+
+		if app.cfg.CacheRevalidate {
+			params = append(params, scrapemate.WithCacheRevalidation())
+		}
+
+		if app.cfg.CacheMaxAge > 0 {
+			params = append(params, scrapemate.WithCacheMaxAge(app.cfg.CacheMaxAge))
+		}
+
+		if app.cfg.HTTPCacheSemantics {
+			params = append(params, scrapemate.WithHTTPCacheSemantics())
+		}
+	}
+
+	// without a cache scrapemate.New fails instead of fetching
+	switch {
+	case app.cfg.CacheOnlySkipMisses:
+		params = append(params, scrapemate.WithCacheOnlySkipMisses())
+	case app.cfg.CacheOnly:
+		params = append(params, scrapemate.WithCacheOnly())
 	}
 
 	if app.cfg.InitJob != nil {
 		params = append(params, scrapemate.WithInitJob(app.cfg.InitJob))
 	}
 
+	params = append(params, app.parserParams()...)
+
+	return scrapemate.New(params...)
+}
+
+// getReprocessMate returns a mate reading the cache only. Nothing is
+// fetched when reprocessing, so no fetcher, browser or WARC writer is
+// created, and the schedules do not run.
+func (app *ScrapemateApp) getReprocessMate(ctx context.Context) (*scrapemate.ScrapeMate, error) {
+	var err error
+
+	app.cacher, err = app.getCacher()
+	if err != nil {
+		return nil, err
+	}
+
+	// no job is pushed while reprocessing, the configured provider is left
+	// alone
+	params := []func(*scrapemate.ScrapeMate) error{
+		scrapemate.WithContext(ctx, app.cancel),
+		scrapemate.WithJobProvider(memprovider.New()),
+		scrapemate.WithHTMLParser(parser.New()),
+		scrapemate.WithConcurrency(app.cfg.Concurrency),
+		scrapemate.WithCache(app.cacher),
+		scrapemate.WithCacheOnly(),
+	}
+
+	params = append(params, app.parserParams()...)
+
 	return scrapemate.New(params...)
 }
 
+// parserParams returns the options parsing the responses
+func (app *ScrapemateApp) parserParams() []func(*scrapemate.ScrapeMate) error {
+	var params []func(*scrapemate.ScrapeMate) error
+
+	if app.cfg.ParseByContentType {
+		params = append(params,
+			scrapemate.WithParser("text/html", parser.New()),
+			scrapemate.WithParser("application/json", jsonparser.New()),
+			scrapemate.WithParser("application/xml", xmlparser.New()),
+			scrapemate.WithParser("text/xml", xmlparser.New()),
+		)
+	}
+
+	if app.cfg.TranscodeCharset {
+		params = append(params, scrapemate.WithCharsetTranscoding())
+	}
+
+	return params
+}
+
 func (app *ScrapemateApp) getCacher() (scrapemate.Cacher, error) {
 	var (
 		cacher scrapemate.Cacher
@@ -138,12 +305,33 @@ func (app *ScrapemateApp) getCacher() (scrapemate.Cacher, error) {
 
 	switch app.cfg.CacheType {
 	case "file":
-		cacher, err = filecache.NewFileCache(app.cfg.CachePath)
+		cacher, err = filecache.NewFileCache(app.cfg.CachePath, filecache.WithMaxSize(app.cfg.CacheMaxSize))
 	case "leveldb":
 		cacher, err = leveldbcache.NewLevelDBCache(app.cfg.CachePath)
 	}
 
-	return cacher, err
+	if err != nil || cacher == nil || !app.cfg.CacheMemoryTier {
+		return cacher, err
+	}
+
+	var options []tieredcache.Option
+
+	if app.cfg.CacheMemoryEntries > 0 {
+		options = append(options, tieredcache.WithMaxEntries(app.cfg.CacheMemoryEntries))
+	}
+
+	if app.cfg.CacheMemoryBytes > 0 {
+		options = append(options, tieredcache.WithMaxBytes(app.cfg.CacheMemoryBytes))
+	}
+
+	tiered, err := tieredcache.New(cacher, options...)
+	if err != nil {
+		_ = cacher.Close()
+
+		return nil, err
+	}
+
+	return tiered, nil
 }
 
 func (app *ScrapemateApp) getFetcher() (scrapemate.HTTPFetcher, error) {
@@ -170,6 +358,7 @@ func (app *ScrapemateApp) getFetcher() (scrapemate.HTTPFetcher, error) {
 			httpFetcher = stealth.New(
 				app.cfg.StealthBrowser,
 				rotator,
+				stealth.WithResponseLimits(app.cfg.ResponseLimits),
 			)
 		} else {
 			cookieJar, err := cookiejar.New(nil)
@@ -186,7 +375,7 @@ func (app *ScrapemateApp) getFetcher() (scrapemate.HTTPFetcher, error) {
 				netClient.Transport = rotator
 			}
 
-			httpFetcher = fetcher.New(netClient)
+			httpFetcher = fetcher.New(netClient, fetcher.WithResponseLimits(app.cfg.ResponseLimits))
 		}
 	}
 
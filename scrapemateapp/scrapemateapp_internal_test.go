@@ -0,0 +1,99 @@
+package scrapemateapp
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gosom/scrapemate"
+	"github.com/gosom/scrapemate/adapters/cache/filecache"
+)
+
+// collectWriter collects the results it receives
+type collectWriter struct {
+	results []scrapemate.Result
+}
+
+func (w *collectWriter) Run(_ context.Context, in <-chan scrapemate.Result) error {
+	for result := range in {
+		w.results = append(w.results, result)
+	}
+
+	return nil
+}
+
+func TestScrapemateApp_Reprocess(t *testing.T) {
+	ctx := context.Background()
+	cacheDir := t.TempDir()
+	warcDir := filepath.Join(t.TempDir(), "warc")
+
+	job := &scrapemate.Job{ID: "1", Method: "GET", URL: "http://example.com"}
+
+	c, err := filecache.NewFileCache(cacheDir)
+	require.NoError(t, err)
+	require.NoError(t, c.Set(ctx, job.GetCacheKey(), &scrapemate.Response{
+		URL:        job.URL,
+		StatusCode: 200,
+		Job:        scrapemate.DescribeJob(job),
+	}))
+	require.NoError(t, c.Close())
+
+	writer := &collectWriter{}
+
+	cfg, err := NewConfig(
+		[]scrapemate.ResultWriter{writer},
+		WithCache("file", cacheDir),
+		WithWARC(warcDir),
+	)
+	require.NoError(t, err)
+
+	app, err := NewScrapeMateApp(cfg)
+	require.NoError(t, err)
+
+	registry := scrapemate.NewJobRegistry()
+	registry.Register(scrapemate.JobType(job), func(desc *scrapemate.JobDescriptor) (scrapemate.IJob, error) {
+		job := desc.Job()
+
+		return &job, nil
+	})
+
+	require.NoError(t, app.Reprocess(ctx, registry))
+	require.Len(t, writer.results, 1)
+	require.Equal(t, "1", writer.results[0].Job.GetID())
+
+	// nothing is fetched so nothing is archived
+	require.NoDirExists(t, warcDir)
+}
+
+func TestScrapemateApp_getMate(t *testing.T) {
+	jobs := func(context.Context) ([]scrapemate.IJob, error) { return nil, nil }
+
+	cfg, err := NewConfig(
+		[]scrapemate.ResultWriter{&collectWriter{}},
+		WithSchedule(Schedule{Name: "hourly", Every: time.Hour, Jobs: jobs}),
+	)
+	require.NoError(t, err)
+
+	app, err := NewScrapeMateApp(cfg)
+	require.NoError(t, err)
+
+	var wrapped scrapemate.JobProvider
+
+	for i := range 2 {
+		mate, err := app.getMate(context.Background())
+		require.NoError(t, err)
+
+		mate.Close()
+
+		if i == 0 {
+			wrapped = app.scheduler.provider
+		}
+	}
+
+	// the scheduler wraps the provider of the first call once
+	require.Equal(t, scrapemate.JobProvider(app.scheduler), app.provider)
+	require.Same(t, wrapped, app.scheduler.provider)
+}
@@ -16,6 +16,28 @@ type JobProvider interface {
 	Push(ctx context.Context, job IJob) error
 }
 
+// AckingProvider is an optional JobProvider capability for providers
+// leasing the jobs they hand out. The engine calls Ack once a job is
+// finished and Nack with the error when it failed. Jobs interrupted by a
+// shutdown are neither acked nor nacked, so unacknowledged jobs must be
+// redelivered once their visibility timeout expires.
+//
+//go:generate mockgen -destination=mock/mock_acking_provider.go -package=mock . AckingProvider
+type AckingProvider interface {
+	JobProvider
+	// Ack acknowledges that job was processed
+	Ack(ctx context.Context, job IJob) error
+	// Nack reports that processing job failed with err
+	Nack(ctx context.Context, job IJob, err error) error
+}
+
+// JobCodec encodes jobs so they can be persisted or transmitted and
+// decodes them back. JobRegistry implements it.
+type JobCodec interface {
+	EncodeJob(job IJob) ([]byte, error)
+	DecodeJob(data []byte) (IJob, error)
+}
+
 // HTTPFetcher is an interface for http fetchers
 //
 //go:generate mockgen -destination=mock/mock_http_fetcher.go -package=mock . HTTPFetcher
@@ -40,6 +62,28 @@ type Cacher interface {
 	Set(ctx context.Context, key string, value *Response) error
 }
 
+// CacheAdmin is an optional Cacher capability to inspect and invalidate
+// cached entries
+type CacheAdmin interface {
+	// Delete removes the entry with key. Missing keys are not an error.
+	Delete(ctx context.Context, key string) error
+	// Purge removes all the entries whose key starts with prefix
+	// (all of them for an empty prefix) and returns how many were removed
+	Purge(ctx context.Context, prefix string) (int, error)
+	// Iterate calls fn for every entry. Iteration stops at the first error
+	// returned by fn and that error is returned.
+	Iterate(ctx context.Context, fn func(key string, value *Response) error) error
+	// Stats returns the number of entries and their stored size
+	Stats(ctx context.Context) (CacheStats, error)
+}
+
+// CacheStats describes the contents of a cache
+type CacheStats struct {
+	Entries int
+	// Bytes is the stored size of the entries
+	Bytes int64
+}
+
 // ResultWriter is an interface for result writers
 //
 //go:generate mockgen -destination=mock/mock_writer.go -package=mock . ResultWriter